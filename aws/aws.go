@@ -3,6 +3,7 @@ package aws
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,10 +14,9 @@ import (
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
-	"github.com/pkg/errors"
-	"github.com/sethvargo/go-retry"
 	"github.com/tclemos/goit"
 	"github.com/tclemos/goit/log"
+	"github.com/tclemos/goit/wait"
 )
 
 const (
@@ -30,13 +30,17 @@ type SqsQueue struct {
 // Params needed to start a aws container
 type Params struct {
 	goit.ContainerParams
-	Region    string
+	Region string
+
+	// Port binds localstack to a fixed host port, by default docker chooses a free one
 	Port      int
 	SqsQueues []SqsQueue
 }
 
 // Container metadata to load a container for aws environment
 type Container struct {
+	goit.Handle
+	goit.Spec
 	params     Params
 	SqsService *SqsService
 }
@@ -44,23 +48,22 @@ type Container struct {
 // NewContainer creates a new instance of Container
 func NewContainer(p Params) *Container {
 	return &Container{
+		Spec:   p.Spec(),
 		params: p,
 	}
 }
 
 // Options to start a localstack container accordingly to the params
 func (c *Container) Options() (*dockertest.RunOptions, error) {
-	p := c.params.Port
-	if p == 0 {
-		p = port
-	}
-
-	strPort := strconv.Itoa(p)
+	// docker chooses a free host port unless the params set one
+	id := fmt.Sprintf("%d/tcp", port)
 	pb := map[docker.Port][]docker.PortBinding{}
-	pb[docker.Port(fmt.Sprintf("%d/tcp", port))] = []docker.PortBinding{{
-		HostIP:   "0.0.0.0",
-		HostPort: strPort,
-	}}
+	if c.params.Port != 0 {
+		pb[docker.Port(id)] = []docker.PortBinding{{
+			HostIP:   "0.0.0.0",
+			HostPort: strconv.Itoa(c.params.Port),
+		}}
+	}
 
 	repo, tag := c.params.GetRepoTag("localstack/localstack", "latest")
 	env := c.params.MergeEnv([]string{
@@ -69,36 +72,37 @@ func (c *Container) Options() (*dockertest.RunOptions, error) {
 	})
 
 	return &dockertest.RunOptions{
+		Hostname:     c.params.GetAlias("localstack"),
 		Repository:   repo,
 		Tag:          tag,
+		Auth:         c.params.Auth,
 		Env:          env,
+		ExposedPorts: []string{id},
 		PortBindings: pb,
 	}, nil
 }
 
-// AfterStart will wait until the container is ready to be consumed
+// AfterStart will create the queues and the services to consume them
 func (c *Container) AfterStart(ctx context.Context, r *dockertest.Resource) error {
+	l := log.FromContext(ctx)
 
 	// sets the endpoint to aws config
-	awsconfig := CreateConfig(c.params.Port, c.params.Region)
+	awsconfig := CreateConfigWithEndpoint(c.ServiceEndpoint(), c.params.Region)
 
 	s, err := session.NewSession(awsconfig)
 	if err != nil {
-		log.Errorf(err, "failed to create aws session")
+		l.Error("failed to create aws session", "err", err)
 		return err
 	}
 	svc := sqs.New(s)
 
-	// await initialization
-	c.awaitInitialization(ctx, svc)
-
 	// create sqs queues
 	for _, q := range c.params.SqsQueues {
 		_, err := svc.CreateQueue(&sqs.CreateQueueInput{
 			QueueName: aws.String(q.Name),
 		})
 		if err != nil {
-			log.Errorf(err, "failed to create queue: %s", q.Name)
+			l.Error("failed to create queue", "queue", q.Name, "err", err)
 			return err
 		}
 	}
@@ -109,48 +113,36 @@ func (c *Container) AfterStart(ctx context.Context, r *dockertest.Resource) erro
 	return nil
 }
 
-func (c *Container) awaitInitialization(ctx context.Context, svc *sqs.SQS) error {
-	// prepare a connection verification interval. Use a Fibonacci backoff
-	// instead of exponential so wait times scale appropriately.
-	b, err := retry.NewFibonacci(500 * time.Millisecond)
-	if err != nil {
-		err = errors.Wrap(err, "failed to configure retries to wait initialization")
-		return err
+// WaitStrategy waits until localstack reports it is ready, unless the
+// params provide another strategy
+func (c *Container) WaitStrategy() wait.Strategy {
+	if c.params.WaitFor != nil {
+		return c.params.WaitFor
 	}
+	return wait.ForLog(`(?m)^Ready\.`)
+}
 
-	b = retry.WithMaxRetries(10, b)
-	b = retry.WithCappedDuration(100*time.Second, b)
+// ServiceEndpoint returns the url to reach localstack from the host, e.g.
+// http://localhost:49153
+func (c *Container) ServiceEndpoint() string {
+	return c.URL("http", fmt.Sprintf("%d/tcp", port))
+}
 
-	// Tries to create a queue to make sure the localstack is up.
-	var createQueue *sqs.CreateQueueOutput
-	err = retry.Do(ctx, b, func(ctx context.Context) error {
-		createQueue, err = svc.CreateQueue(&sqs.CreateQueueInput{
-			QueueName: aws.String("test-Resource"),
-		})
-		if err != nil {
-			log.Log("waiting aws server to initialize...")
-			return retry.RetryableError(err)
-		}
-		log.Log("aws server initialized")
-		return nil
-	})
+// CreateConfig creates the aws config to reach localstack on a fixed
+// host port of the docker host, prefer CreateConfigWithEndpoint with the
+// container ServiceEndpoint
+func CreateConfig(port int, region string) *aws.Config {
+	host, err := goit.DockerHost()
 	if err != nil {
-		log.Error(err, "failed to initialize aws server")
-		return err
-	}
-
-	if _, err := svc.DeleteQueue(&sqs.DeleteQueueInput{
-		QueueUrl: createQueue.QueueUrl,
-	}); err != nil {
-		return err
+		host = "localhost"
 	}
-
-	return nil
+	return CreateConfigWithEndpoint(fmt.Sprintf("http://%s", net.JoinHostPort(host, strconv.Itoa(port))), region)
 }
 
-func CreateConfig(port int, region string) *aws.Config {
+// CreateConfigWithEndpoint creates the aws config to reach localstack at the endpoint
+func CreateConfigWithEndpoint(endpoint, region string) *aws.Config {
 	return aws.NewConfig().
-		WithEndpoint(fmt.Sprintf("http://localhost:%d", port)).
+		WithEndpoint(endpoint).
 		WithCredentialsChainVerboseErrors(true).
 		WithHTTPClient(&http.Client{Timeout: 10 * time.Second}).
 		WithMaxRetries(2).
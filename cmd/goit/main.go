@@ -0,0 +1,107 @@
+// Command goit manages the environment of a goit.yaml outside go test, so
+// the containers are started once and shared by the test runs:
+//
+//	goit up                 starts the containers and prints their env vars
+//	goit ps                 lists the containers of the environment
+//	goit logs [-f] [name]   prints the output of the containers
+//	goit env                prints the env vars of the environment
+//	goit down               removes the containers, volumes and networks
+//
+// The test runs loading the same goit.yaml attach to the containers of
+// the environment instead of starting new ones, as long as they are
+// created with the same options. Other test runs attach to it when
+// GOIT_ATTACH names it, GOIT_ATTACH=false starts new containers.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	stdlog "log"
+	"os"
+	"os/signal"
+
+	"github.com/tclemos/goit/config"
+	"github.com/tclemos/goit/log"
+)
+
+const usage = `usage: goit <command> [flags]
+
+commands:
+  up      start the containers of the goit.yaml and keep them running
+  down    remove the containers, volumes and networks of the environment
+  ps      list the containers of the environment
+  logs    print the output of the containers
+  env     print the connection details of the environment as env vars
+
+run goit <command> -h for the flags of a command
+`
+
+// command runs a subcommand with its arguments
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"up":   up,
+	"down": down,
+	"ps":   ps,
+	"logs": logs,
+	"env":  env,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	// the goit messages go to stderr, so stdout can be evaluated
+	l := log.NewStd(stdlog.New(os.Stderr, "", stdlog.LstdFlags))
+	ctx = log.NewContext(ctx, l)
+
+	if err := cmd(ctx, os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "goit %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// envFlags are the flags selecting the environment, shared by the commands
+type envFlags struct {
+	file string
+	name string
+}
+
+func newFlagSet(name string, f *envFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet("goit "+name, flag.ExitOnError)
+	fs.StringVar(&f.file, "f", "", "path of the goit.yaml, defaults to GOIT_CONFIG or goit.yaml")
+	fs.StringVar(&f.name, "name", "", "name of the environment, defaults to the one of the goit.yaml, set GOIT_ATTACH to it in the tests loading another file")
+	return fs
+}
+
+// configFile returns the path of the goit.yaml
+func (f envFlags) configFile() string {
+	switch {
+	case f.file != "":
+		return f.file
+	case os.Getenv("GOIT_CONFIG") != "":
+		return os.Getenv("GOIT_CONFIG")
+	}
+	return config.DefaultFile
+}
+
+// envName returns the name of the environment, the one the test runs
+// loading the goit.yaml attach to, unless the flag sets it
+func (f envFlags) envName() string {
+	if f.name != "" {
+		return f.name
+	}
+	return config.UpName(f.configFile())
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit"
+)
+
+// ps lists the containers of the environment
+func ps(ctx context.Context, args []string) error {
+	var f envFlags
+	fs := newFlagSet("ps", &f)
+	all := fs.Bool("all", false, "list the containers of all the environments started by goit up")
+	_ = fs.Parse(args)
+
+	name := ""
+	if !*all {
+		name = f.envName()
+	}
+
+	p, err := goit.NewPool()
+	if err != nil {
+		return err
+	}
+	cs, err := listContainers(ctx, p, name)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ENVIRONMENT\tNAME\tIMAGE\tSTATUS\tPORTS")
+	for _, c := range cs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Labels[goit.LabelUp], containerName(c), c.Image, c.Status, formatPorts(c.Ports))
+	}
+	return w.Flush()
+}
+
+// logs prints the output of the containers of the environment, all of
+// them unless their names are given
+func logs(ctx context.Context, args []string) error {
+	var f envFlags
+	fs := newFlagSet("logs", &f)
+	follow := fs.Bool("f", false, "follow the output")
+	tail := fs.String("tail", "all", "number of lines to show from the end of the output")
+	_ = fs.Parse(args)
+
+	name := f.envName()
+
+	p, err := goit.NewPool()
+	if err != nil {
+		return err
+	}
+	cs, err := listContainers(ctx, p, name)
+	if err != nil {
+		return err
+	}
+
+	if names := fs.Args(); len(names) > 0 {
+		cs, err = selectContainers(cs, names)
+		if err != nil {
+			return err
+		}
+	}
+	if len(cs) == 0 {
+		return errors.Errorf("environment %s has no containers, start it with goit up", name)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for _, c := range cs {
+		var out io.Writer = os.Stdout
+		if len(cs) > 1 {
+			out = &prefixWriter{prefix: containerName(c) + " | ", mu: &mu, w: os.Stdout}
+		}
+
+		wg.Add(1)
+		go func(c docker.APIContainers) {
+			defer wg.Done()
+			err := p.Client.Logs(docker.LogsOptions{
+				Context:      ctx,
+				Container:    c.ID,
+				OutputStream: out,
+				ErrorStream:  out,
+				Stdout:       true,
+				Stderr:       true,
+				Follow:       *follow,
+				Tail:         *tail,
+			})
+			if err != nil && ctx.Err() == nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = errors.Wrapf(err, "failed to read logs of container: %s", containerName(c))
+				}
+				mu.Unlock()
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// listContainers returns the containers of the goit up environment with
+// the name, or of all of them when the name is empty, sorted by name
+func listContainers(ctx context.Context, p *dockertest.Pool, name string) ([]docker.APIContainers, error) {
+	filter := goit.LabelUp
+	if name != "" {
+		filter += "=" + name
+	}
+
+	cs, err := p.Client.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Filters: map[string][]string{"label": {filter}},
+		Context: ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(cs, func(i, j int) bool {
+		return containerName(cs[i]) < containerName(cs[j])
+	})
+	return cs, nil
+}
+
+// selectContainers returns the containers with the names, which are the
+// container names or their hostnames
+func selectContainers(cs []docker.APIContainers, names []string) ([]docker.APIContainers, error) {
+	var selected []docker.APIContainers
+	for _, n := range names {
+		found := false
+		for _, c := range cs {
+			if containerName(c) == n || strings.HasPrefix(containerName(c), n+"-") {
+				selected = append(selected, c)
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unknown container: %s", n)
+		}
+	}
+	return selected, nil
+}
+
+func containerName(c docker.APIContainers) string {
+	if len(c.Names) == 0 {
+		return c.ID[:12]
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// formatPorts formats the published ports as docker ps does, e.g.
+// 0.0.0.0:49153->5432/tcp
+func formatPorts(ports []docker.APIPort) string {
+	var ps []string
+	for _, p := range ports {
+		if p.PublicPort == 0 {
+			continue
+		}
+		ps = append(ps, fmt.Sprintf("%s:%d->%d/%s", p.IP, p.PublicPort, p.PrivatePort, p.Type))
+	}
+	sort.Strings(ps)
+	return strings.Join(ps, ", ")
+}
+
+// prefixWriter writes the lines of a container prefixed by its name, the
+// lines of the containers are written whole under the shared mutex
+type prefixWriter struct {
+	prefix string
+	mu     *sync.Mutex
+	w      io.Writer
+	buf    bytes.Buffer
+}
+
+func (pw *prefixWriter) Write(b []byte) (int, error) {
+	pw.buf.Write(b)
+	for {
+		i := bytes.IndexByte(pw.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+
+		line := pw.buf.Next(i + 1)
+		pw.mu.Lock()
+		_, err := fmt.Fprintf(pw.w, "%s%s", pw.prefix, line)
+		pw.mu.Unlock()
+		if err != nil {
+			return len(b), err
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &prefixWriter{prefix: "db | ", mu: &sync.Mutex{}, w: &out}
+
+	_, _ = w.Write([]byte("first\nsec"))
+	_, _ = w.Write([]byte("ond\n"))
+
+	if want := "db | first\ndb | second\n"; out.String() != want {
+		t.Errorf("expected %q, found: %q", want, out.String())
+	}
+}
+
+func TestFormatPorts(t *testing.T) {
+	ports := []docker.APIPort{
+		{PrivatePort: 5432, PublicPort: 49153, Type: "tcp", IP: "0.0.0.0"},
+		{PrivatePort: 8080, Type: "tcp"},
+	}
+	if want := "0.0.0.0:49153->5432/tcp"; formatPorts(ports) != want {
+		t.Errorf("expected %s, found: %s", want, formatPorts(ports))
+	}
+}
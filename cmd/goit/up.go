@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit"
+	"github.com/tclemos/goit/config"
+	"github.com/tclemos/goit/log"
+)
+
+// up starts the containers of the goit.yaml, they keep running after goit
+// exits and the test runs attach to them
+func up(ctx context.Context, args []string) error {
+	var f envFlags
+	fs := newFlagSet("up", &f)
+	_ = fs.Parse(args)
+
+	name := f.envName()
+	e, err := config.Load(f.configFile())
+	if err != nil {
+		return err
+	}
+
+	e.Options.Up = name
+	e.Options.Logger = log.FromContext(ctx)
+	if err := goit.StartE(ctx, e.Options, e.Containers()...); err != nil {
+		return err
+	}
+
+	vars := e.Vars()
+	if err := writeVars(name, vars); err != nil {
+		return err
+	}
+	for _, v := range vars {
+		fmt.Println(v)
+	}
+
+	log.FromContext(ctx).Info("environment is up, remove it with goit down", "environment", name)
+	return nil
+}
+
+// down removes the containers, volumes and networks of the environment
+func down(ctx context.Context, args []string) error {
+	var f envFlags
+	fs := newFlagSet("down", &f)
+	all := fs.Bool("all", false, "remove all the environments started by goit up")
+	_ = fs.Parse(args)
+
+	name := ""
+	if !*all {
+		name = f.envName()
+	}
+
+	if err := goit.Down(ctx, name); err != nil {
+		return err
+	}
+	return removeVars(name)
+}
+
+// env prints the connection details saved by goit up
+func env(ctx context.Context, args []string) error {
+	var f envFlags
+	fs := newFlagSet("env", &f)
+	_ = fs.Parse(args)
+
+	name := f.envName()
+
+	path, err := varsFile(name)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return errors.Errorf("environment %s is not up, start it with goit up", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(b))
+	return nil
+}
+
+// varsFile returns the file where goit up saves the env vars of the
+// environment, so goit env prints them without starting the containers
+func varsFile(name string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goit", "up", name+".env"), nil
+}
+
+func writeVars(name string, vars []string) error {
+	path, err := varsFile(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, v := range vars {
+		b.WriteString(v + "\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// removeVars removes the env vars of the environment, or of all of them
+// when the name is empty
+func removeVars(name string) error {
+	path, err := varsFile(name)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return os.RemoveAll(filepath.Dir(path))
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
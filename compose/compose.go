@@ -0,0 +1,189 @@
+// Package compose starts the services of a docker-compose file as goit
+// containers, e.g.
+//
+//	p, err := compose.Load(compose.Params{File: "docker-compose.yml"})
+//	...
+//	goit.Main(m, goit.DefaultOptions(), p.Containers()...)
+//
+// The compose networks are mapped onto the goit environment network,
+// where the services reach each other by their hostnames and the aliases
+// of all their networks, services in different networks aren't isolated.
+// Healthchecks are executed by goit to wait until the services are ready.
+package compose
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit"
+	"github.com/tclemos/goit/internal/spec"
+)
+
+// defaultFile is the compose file loaded when Params.File is empty
+const defaultFile = "docker-compose.yml"
+
+// Params needed to load a compose file
+type Params struct {
+	// File is the path of the compose file, defaults to docker-compose.yml
+	File string
+
+	// ProjectName prefixes the named volumes, defaults to the name of the
+	// compose file directory
+	ProjectName string
+
+	// Services to start with their dependencies, defaults to all services
+	Services []string
+
+	// Env replaces the host environment variables in the compose file
+	// interpolation, the .env file next to the compose file is used too
+	Env map[string]string
+
+	// AfterStart is executed after the service with the name is ready,
+	// e.g. to run migrations
+	AfterStart map[string]func(context.Context, *Service) error
+}
+
+// Project is a loaded compose file
+type Project struct {
+	name     string
+	services map[string]*Service
+	order    []string
+}
+
+// Load parses the compose file and creates a container for each service
+func Load(p Params) (*Project, error) {
+	path := p.File
+	if path == "" {
+		path = defaultFile
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(path)
+
+	dotEnv, err := spec.ReadEnvFile(filepath.Join(dir, ".env"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read .env file")
+	}
+	lookup := func(k string) (string, bool) {
+		if v, ok := p.Env[k]; ok {
+			return v, true
+		}
+		if v, ok := os.LookupEnv(k); ok {
+			return v, true
+		}
+		v, ok := dotEnv[k]
+		return v, ok
+	}
+
+	f, err := parseFile(path, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	name := p.ProjectName
+	if name == "" {
+		name = filepath.Base(dir)
+	}
+	name = strings.ToLower(name)
+
+	pr := &Project{name: name, services: map[string]*Service{}}
+	for n, s := range f.Services {
+		svc, err := newService(n, s, f, pr, dir, lookup)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid service: %s", n)
+		}
+		svc.afterStart = p.AfterStart[n]
+		pr.services[n] = svc
+	}
+
+	for n, s := range pr.services {
+		for _, d := range f.Services[n].DependsOn {
+			dep, ok := pr.services[d]
+			if !ok {
+				return nil, errors.Errorf("service %s depends on unknown service: %s", n, d)
+			}
+			s.dependsOn = append(s.dependsOn, dep)
+		}
+	}
+
+	pr.order, err = pr.selected(p.Services)
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// selected returns the names of the services with their dependencies
+func (pr *Project) selected(names []string) ([]string, error) {
+	if len(names) == 0 {
+		for n := range pr.services {
+			names = append(names, n)
+		}
+	}
+
+	seen := map[string]bool{}
+	var visit func(n string) error
+	visit = func(n string) error {
+		s, ok := pr.services[n]
+		if !ok {
+			return errors.Errorf("unknown service: %s", n)
+		}
+		if seen[n] {
+			return nil
+		}
+		seen[n] = true
+		for _, d := range s.dependsOn {
+			if err := visit(d.name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, n := range names {
+		if err := visit(n); err != nil {
+			return nil, err
+		}
+	}
+
+	var order []string
+	for n := range seen {
+		order = append(order, n)
+	}
+	sort.Strings(order)
+	return order, nil
+}
+
+// Name of the project
+func (pr *Project) Name() string {
+	return pr.name
+}
+
+// Containers returns the containers of the selected services, start them
+// with goit, the dependencies are started first
+func (pr *Project) Containers() []goit.Container {
+	cs := make([]goit.Container, 0, len(pr.order))
+	for _, n := range pr.order {
+		cs = append(cs, pr.services[n].container())
+	}
+	return cs
+}
+
+// Service returns the service with the name, or nil if it doesn't exist
+func (pr *Project) Service(name string) *Service {
+	return pr.services[name]
+}
+
+// Services returns the selected services sorted by name
+func (pr *Project) Services() []*Service {
+	ss := make([]*Service, 0, len(pr.order))
+	for _, n := range pr.order {
+		ss = append(ss, pr.services[n])
+	}
+	return ss
+}
@@ -0,0 +1,151 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/tclemos/goit"
+)
+
+func TestLoad(t *testing.T) {
+	pr, err := Load(Params{
+		File:     "testdata/docker-compose.yml",
+		Services: []string{"app"},
+		Env:      map[string]string{"FROM_HOST": "value"},
+	})
+	if err != nil {
+		t.Fatalf("failed to load compose file: %v", err)
+	}
+
+	if pr.Name() != "testdata" {
+		t.Errorf("expected the directory as project name, found: %s", pr.Name())
+	}
+	if cs := pr.Containers(); len(cs) != 2 {
+		t.Errorf("expected app and its dependency, found: %d containers", len(cs))
+	}
+	if pr.Service("worker") == nil {
+		t.Errorf("expected the services to be loaded even when not selected")
+	}
+
+	db := pr.Service("db")
+	o, err := db.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Repository != "postgres" || o.Tag != "14" || o.Hostname != "db" {
+		t.Errorf("invalid image or alias: %s:%s, %s", o.Repository, o.Tag, o.Hostname)
+	}
+	wantEnv := []string{"FROM_HOST=value", "POSTGRES_DB=app", "POSTGRES_PASSWORD=secret"}
+	if !reflect.DeepEqual(o.Env, wantEnv) {
+		t.Errorf("expected env %v, found: %v", wantEnv, o.Env)
+	}
+	wantBindings := map[docker.Port][]docker.PortBinding{"5432/tcp": {{HostIP: "127.0.0.1", HostPort: "15432"}}}
+	if !reflect.DeepEqual(o.PortBindings, wantBindings) {
+		t.Errorf("expected bindings %v, found: %v", wantBindings, o.PortBindings)
+	}
+
+	dir, _ := filepath.Abs("testdata")
+	wantMounts := []goit.Mount{
+		goit.VolumeMount("testdata_data", "/var/lib/postgresql/data"),
+		{Type: goit.MountBind, Source: filepath.Join(dir, "init"), Target: "/docker-entrypoint-initdb.d", ReadOnly: true},
+		goit.TmpfsMount("/run", 64<<20),
+	}
+	if !reflect.DeepEqual(db.Mounts(), wantMounts) {
+		t.Errorf("expected mounts %+v, found: %+v", wantMounts, db.Mounts())
+	}
+	if r := db.Resources(); r.Memory != 512<<20 || len(r.Ulimits) != 1 || r.Ulimits[0].Hard != 1024 {
+		t.Errorf("invalid resources: %+v", r)
+	}
+	if db.WaitStrategy() == nil {
+		t.Errorf("expected the healthcheck to be the wait strategy")
+	}
+
+	app, ok := pr.Service("app").container().(*builtService)
+	if !ok {
+		t.Fatalf("expected app to be built from its dockerfile")
+	}
+	if app.ContainerName() != "testdata-app" || app.Hostname() != "app" {
+		t.Errorf("expected the project image and the service hostname, found: %s, %s", app.ContainerName(), app.Hostname())
+	}
+	if app.DockerFilePath() != filepath.Join(dir, "app", "Dockerfile") {
+		t.Errorf("invalid dockerfile: %s", app.DockerFilePath())
+	}
+	if !reflect.DeepEqual(app.Env(), []string{"DB_HOST=db", "LOG_LEVEL=debug"}) {
+		t.Errorf("expected the environment to override the env_file, found: %v", app.Env())
+	}
+	if r := app.Resources(); r.CPUs != 0.5 || r.Memory != 256<<20 {
+		t.Errorf("expected the deploy limits, found: %+v", r)
+	}
+	if deps := app.DependsOn(); len(deps) != 1 || deps[0] != goit.Container(db) {
+		t.Errorf("expected app to depend on db, found: %v", deps)
+	}
+
+	worker := pr.Service("worker")
+	o, _ = worker.Options()
+	if o.Repository != "localhost:5000/worker" || o.Tag != "latest" {
+		t.Errorf("invalid image: %s:%s", o.Repository, o.Tag)
+	}
+	if !reflect.DeepEqual(o.Cmd, []string{"run", "--queue", "high priority"}) {
+		t.Errorf("invalid command: %q", o.Cmd)
+	}
+	if worker.PullPolicy() != goit.PullNever {
+		t.Errorf("expected pull policy never, found: %s", worker.PullPolicy())
+	}
+}
+
+func TestNetworks(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		aliases []string
+	}{
+		"default": {"services:\n  db:\n    image: postgres\n    networks: [default]\n", nil},
+		"other":   {"services:\n  db:\n    image: postgres\n    networks: [backend]\nnetworks:\n  backend:\n", nil},
+		"aliases": {
+			"services:\n  db:\n    image: postgres\n    networks:\n      default:\n        aliases: [database]\n      backend:\n        aliases: [pg, database]\nnetworks:\n  backend:\n",
+			[]string{"database", "pg"},
+		},
+	}
+
+	dir := t.TempDir()
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".yml")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			pr, err := Load(Params{File: path})
+			if err != nil {
+				t.Fatalf("expected the file to be loaded, found: %v", err)
+			}
+			if got := pr.Service("db").Aliases(); !reflect.DeepEqual(got, tt.aliases) {
+				t.Errorf("expected aliases %v, found: %v", tt.aliases, got)
+			}
+		})
+	}
+
+	path := filepath.Join(dir, "undefined.yml")
+	if err := os.WriteFile(path, []byte("services:\n  db:\n    image: postgres\n    networks: [backend]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(Params{File: path}); err == nil {
+		t.Errorf("expected the undefined network to be rejected")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := map[string][]string{
+		`sh -c "echo hello"`: {"sh", "-c", "echo hello"},
+		`echo 'a "b"' c\ d`:  {"echo", `a "b"`, "c d"},
+		`  spaced   out  `:   {"spaced", "out"},
+		`empty "" arg`:       {"empty", "", "arg"},
+	}
+	for in, want := range tests {
+		got, err := splitArgs(in)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %q for %q, found: %q, err: %v", want, in, got, err)
+		}
+	}
+}
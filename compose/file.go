@@ -0,0 +1,458 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit/internal/spec"
+	"gopkg.in/yaml.v2"
+)
+
+// file is the part of a compose file goit understands, the other keys are
+// ignored
+type file struct {
+	Services map[string]*service    `yaml:"services"`
+	Volumes  map[string]*volume     `yaml:"volumes"`
+	Networks map[string]interface{} `yaml:"networks"`
+}
+
+type volume struct {
+	Name     string `yaml:"name"`
+	External bool   `yaml:"external"`
+}
+
+type service struct {
+	Image       string            `yaml:"image"`
+	Build       *build            `yaml:"build"`
+	Hostname    string            `yaml:"hostname"`
+	Command     command           `yaml:"command"`
+	Entrypoint  command           `yaml:"entrypoint"`
+	Environment dict              `yaml:"environment"`
+	EnvFile     list              `yaml:"env_file"`
+	Ports       []port            `yaml:"ports"`
+	Expose      []string          `yaml:"expose"`
+	Volumes     []mount           `yaml:"volumes"`
+	Tmpfs       list              `yaml:"tmpfs"`
+	DependsOn   dependencies      `yaml:"depends_on"`
+	Healthcheck *healthcheck      `yaml:"healthcheck"`
+	Networks    networks          `yaml:"networks"`
+	WorkingDir  string            `yaml:"working_dir"`
+	ExtraHosts  dict              `yaml:"extra_hosts"`
+	CapAdd      []string          `yaml:"cap_add"`
+	SecurityOpt []string          `yaml:"security_opt"`
+	DNS         list              `yaml:"dns"`
+	Privileged  bool              `yaml:"privileged"`
+	Labels      dict              `yaml:"labels"`
+	PullPolicy  string            `yaml:"pull_policy"`
+	MemLimit    size              `yaml:"mem_limit"`
+	ShmSize     size              `yaml:"shm_size"`
+	CPUs        float64           `yaml:"cpus"`
+	CPUShares   int64             `yaml:"cpu_shares"`
+	CPUQuota    int64             `yaml:"cpu_quota"`
+	CPUPeriod   int64             `yaml:"cpu_period"`
+	PidsLimit   int64             `yaml:"pids_limit"`
+	Ulimits     map[string]ulimit `yaml:"ulimits"`
+	Deploy      struct {
+		Resources struct {
+			Limits struct {
+				CPUs   string `yaml:"cpus"`
+				Memory size   `yaml:"memory"`
+				Pids   int64  `yaml:"pids"`
+			} `yaml:"limits"`
+		} `yaml:"resources"`
+	} `yaml:"deploy"`
+}
+
+type build struct {
+	Context    string `yaml:"context"`
+	Dockerfile string `yaml:"dockerfile"`
+	Args       dict   `yaml:"args"`
+}
+
+// UnmarshalYAML accepts the build context as a string
+func (b *build) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		b.Context = s
+		return nil
+	}
+
+	type plain build
+	return unmarshal((*plain)(b))
+}
+
+type healthcheck struct {
+	Test        healthTest `yaml:"test"`
+	Interval    duration   `yaml:"interval"`
+	Timeout     duration   `yaml:"timeout"`
+	Retries     int        `yaml:"retries"`
+	StartPeriod duration   `yaml:"start_period"`
+	Disable     bool       `yaml:"disable"`
+}
+
+// healthTest is a shell command or a list starting with CMD, CMD-SHELL
+// or NONE
+type healthTest []string
+
+func (t *healthTest) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		*t = healthTest{"CMD-SHELL", s}
+		return nil
+	}
+	return unmarshal((*[]string)(t))
+}
+
+// list is a string or a list of strings, e.g. env_file or dns
+type list []string
+
+func (l *list) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		*l = list{s}
+		return nil
+	}
+	return unmarshal((*[]string)(l))
+}
+
+// command is a shell-like string or a list of arguments
+type command []string
+
+func (c *command) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		args, err := splitArgs(s)
+		*c = args
+		return err
+	}
+	return unmarshal((*[]string)(c))
+}
+
+// dict is a map or a list of key=value, values without = are nil, e.g.
+// environment variables taken from the host
+type dict map[string]*string
+
+func (d *dict) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*d = dict{}
+
+	var l []string
+	if err := unmarshal(&l); err == nil {
+		for _, kv := range l {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) == 1 {
+				// extra_hosts use host:ip
+				parts = strings.SplitN(kv, ":", 2)
+			}
+			if len(parts) == 2 {
+				v := parts[1]
+				(*d)[parts[0]] = &v
+			} else {
+				(*d)[parts[0]] = nil
+			}
+		}
+		return nil
+	}
+
+	var m map[string]interface{}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	for k, v := range m {
+		if v == nil {
+			(*d)[k] = nil
+			continue
+		}
+		s := fmt.Sprint(v)
+		(*d)[k] = &s
+	}
+	return nil
+}
+
+// port is a published or exposed port, e.g. 127.0.0.1:8080:80/tcp
+type port struct {
+	Target    string
+	HostIP    string
+	Published string
+}
+
+func (p *port) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var long struct {
+		Target    int    `yaml:"target"`
+		Published string `yaml:"published"`
+		HostIP    string `yaml:"host_ip"`
+		Protocol  string `yaml:"protocol"`
+	}
+	var s string
+	if err := unmarshal(&s); err != nil {
+		if err := unmarshal(&long); err != nil {
+			return err
+		}
+		proto := long.Protocol
+		if proto == "" {
+			proto = "tcp"
+		}
+		*p = port{Target: fmt.Sprintf("%d/%s", long.Target, proto), HostIP: long.HostIP, Published: long.Published}
+		return nil
+	}
+
+	proto := "tcp"
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		s, proto = s[:i], s[i+1:]
+	}
+
+	parts := strings.Split(s, ":")
+	target := parts[len(parts)-1]
+	if strings.Contains(target, "-") {
+		return errors.Errorf("port ranges are not supported: %s", s)
+	}
+
+	*p = port{Target: target + "/" + proto}
+	switch len(parts) {
+	case 2:
+		p.Published = parts[0]
+	case 3:
+		p.HostIP, p.Published = parts[0], parts[1]
+	}
+	return nil
+}
+
+// mount is a volume of a service, e.g. ./data:/data:ro
+type mount struct {
+	Type     string `yaml:"type"`
+	Source   string `yaml:"source"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"read_only"`
+	Tmpfs    struct {
+		Size size `yaml:"size"`
+	} `yaml:"tmpfs"`
+}
+
+func (m *mount) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		type plain mount
+		return unmarshal((*plain)(m))
+	}
+
+	parts := strings.Split(s, ":")
+	switch len(parts) {
+	case 1:
+		*m = mount{Type: "volume", Target: parts[0]}
+		return nil
+	case 2, 3:
+		*m = mount{Type: "volume", Source: parts[0], Target: parts[1]}
+	default:
+		return errors.Errorf("invalid volume: %s", s)
+	}
+
+	if isPath(m.Source) {
+		m.Type = "bind"
+	}
+	if len(parts) == 3 {
+		for _, o := range strings.Split(parts[2], ",") {
+			m.ReadOnly = m.ReadOnly || o == "ro"
+		}
+	}
+	return nil
+}
+
+// isPath reports if the volume source is a host path instead of a volume name
+func isPath(s string) bool {
+	return strings.HasPrefix(s, ".") || strings.HasPrefix(s, "/") || strings.HasPrefix(s, "~")
+}
+
+// dependencies is a list of services or a map of services to conditions
+type dependencies []string
+
+func (d *dependencies) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var l []string
+	if err := unmarshal(&l); err == nil {
+		*d = l
+		return nil
+	}
+
+	var m map[string]interface{}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	for k := range m {
+		*d = append(*d, k)
+	}
+	return nil
+}
+
+// networks is a list of networks or a map of networks to their settings
+type networks map[string]*serviceNetwork
+
+type serviceNetwork struct {
+	Aliases []string `yaml:"aliases"`
+}
+
+func (n *networks) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*n = networks{}
+
+	var l []string
+	if err := unmarshal(&l); err == nil {
+		for _, name := range l {
+			(*n)[name] = nil
+		}
+		return nil
+	}
+	return unmarshal((*map[string]*serviceNetwork)(n))
+}
+
+// defaultNetwork is the compose network of the services without networks
+const defaultNetwork = "default"
+
+// aliases returns the aliases of the service in all its networks, sorted
+// and without duplicates
+func (n networks) aliases() []string {
+	seen := map[string]bool{}
+	var as []string
+	for _, sn := range n {
+		if sn == nil {
+			continue
+		}
+		for _, a := range sn.Aliases {
+			if !seen[a] {
+				seen[a] = true
+				as = append(as, a)
+			}
+		}
+	}
+	sort.Strings(as)
+	return as
+}
+
+// checkNetworks rejects the services using networks the file doesn't
+// declare, as compose does
+func checkNetworks(f *file) error {
+	for sn, s := range f.Services {
+		for name := range s.Networks {
+			if _, ok := f.Networks[name]; !ok && name != defaultNetwork {
+				return errors.Errorf("service %s uses undefined network: %s", sn, name)
+			}
+		}
+	}
+	return nil
+}
+
+// ulimit is a single value or soft and hard values
+type ulimit struct {
+	Soft int64 `yaml:"soft"`
+	Hard int64 `yaml:"hard"`
+}
+
+func (u *ulimit) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v int64
+	if err := unmarshal(&v); err == nil {
+		*u = ulimit{Soft: v, Hard: v}
+		return nil
+	}
+
+	type plain ulimit
+	return unmarshal((*plain)(u))
+}
+
+// duration is a compose duration, e.g. 1m30s
+type duration time.Duration
+
+func (d *duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	*d = duration(v)
+	return err
+}
+
+// size is a number of bytes or a size with unit, e.g. 512m
+type size int64
+
+func (s *size) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err != nil {
+		return err
+	}
+	v, err := spec.ParseSize(str)
+	*s = size(v)
+	return err
+}
+
+// splitArgs splits a command line like a shell does, with single and
+// double quotes and backslash escapes
+func splitArgs(s string) ([]string, error) {
+	var (
+		args    []string
+		cur     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errors.Errorf("unterminated quote in command: %s", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// parseFile reads and interpolates the compose file
+func parseFile(path string, lookup func(string) (string, bool)) (*file, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read compose file: %s", path)
+	}
+
+	s, err := spec.Interpolate(string(b), lookup)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to interpolate compose file: %s", path)
+	}
+
+	var f file
+	if err := yaml.Unmarshal([]byte(s), &f); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse compose file: %s", path)
+	}
+	if len(f.Services) == 0 {
+		return nil, errors.Errorf("compose file has no services: %s", path)
+	}
+	if err := checkNetworks(&f); err != nil {
+		return nil, errors.Wrapf(err, "invalid compose file: %s", path)
+	}
+	return &f, nil
+}
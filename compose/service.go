@@ -0,0 +1,504 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit"
+	"github.com/tclemos/goit/internal/spec"
+	"github.com/tclemos/goit/wait"
+)
+
+// minHealthTimeout is the minimum time goit waits for a healthcheck, the
+// services also need time to start before their first check
+const minHealthTimeout = 60 * time.Second
+
+// Service is a container started from a compose service, its endpoints
+// are available through the embedded goit.Handle once it is started.
+//
+// Services with build are built from their dockerfile, which must be in
+// the root of the build context, their command and entrypoint are the
+// ones of the dockerfile. Their image is named project-service and their
+// containers get unique names, so environments loading the same file
+// don't collide.
+type Service struct {
+	goit.Handle
+
+	name       string
+	repository string
+	tag        string
+	build      *builtService
+
+	hostname    string
+	aliases     []string
+	env         []string
+	entrypoint  []string
+	cmd         []string
+	exposed     []string
+	bindings    map[docker.Port][]docker.PortBinding
+	mounts      []goit.Mount
+	resources   goit.Resources
+	pullPolicy  goit.PullPolicy
+	waitFor     wait.Strategy
+	workingDir  string
+	extraHosts  []string
+	capAdd      []string
+	securityOpt []string
+	dns         []string
+	privileged  bool
+	labels      map[string]string
+
+	dependsOn  []*Service
+	afterStart func(context.Context, *Service) error
+}
+
+// builtService is a service started from a dockerfile instead of an image
+type builtService struct {
+	*Service
+	image      string
+	dockerfile string
+	args       []docker.BuildArg
+}
+
+func newService(name string, s *service, f *file, pr *Project, dir string, lookup func(string) (string, bool)) (*Service, error) {
+	svc := &Service{
+		name:        name,
+		hostname:    s.Hostname,
+		aliases:     s.Networks.aliases(),
+		entrypoint:  s.Entrypoint,
+		cmd:         s.Command,
+		workingDir:  s.WorkingDir,
+		capAdd:      s.CapAdd,
+		securityOpt: s.SecurityOpt,
+		dns:         s.DNS,
+		privileged:  s.Privileged,
+		labels:      values(s.Labels, nil),
+		bindings:    map[docker.Port][]docker.PortBinding{},
+	}
+	if svc.hostname == "" {
+		svc.hostname = name
+	}
+
+	switch {
+	case s.Build != nil:
+		b, err := newBuild(svc, s.Build, pr.name, dir)
+		if err != nil {
+			return nil, err
+		}
+		svc.build = b
+	case s.Image != "":
+		repo, tag, err := splitImage(s.Image)
+		if err != nil {
+			return nil, err
+		}
+		svc.repository, svc.tag = repo, tag
+	default:
+		return nil, errors.New("service has no image nor build")
+	}
+
+	env, err := serviceEnv(s, dir, lookup)
+	if err != nil {
+		return nil, err
+	}
+	svc.env = env
+
+	for h, ip := range s.ExtraHosts {
+		if ip != nil {
+			svc.extraHosts = append(svc.extraHosts, h+":"+*ip)
+		}
+	}
+	sort.Strings(svc.extraHosts)
+
+	for _, e := range s.Expose {
+		if !strings.Contains(e, "/") {
+			e += "/tcp"
+		}
+		svc.exposed = append(svc.exposed, e)
+	}
+	for _, p := range s.Ports {
+		svc.exposed = append(svc.exposed, p.Target)
+		if p.Published != "" {
+			id := docker.Port(p.Target)
+			svc.bindings[id] = append(svc.bindings[id], docker.PortBinding{HostIP: p.HostIP, HostPort: p.Published})
+		}
+	}
+
+	if svc.mounts, err = serviceMounts(s, f, pr.name, dir); err != nil {
+		return nil, err
+	}
+	if svc.resources, err = serviceResources(s); err != nil {
+		return nil, err
+	}
+	if svc.pullPolicy, err = pullPolicy(s.PullPolicy); err != nil {
+		return nil, err
+	}
+	svc.waitFor = healthStrategy(s.Healthcheck)
+
+	return svc, nil
+}
+
+// container returns the goit container of the service
+func (s *Service) container() goit.Container {
+	if s.build != nil {
+		return s.build
+	}
+	return s
+}
+
+// Name of the service in the compose file
+func (s *Service) Name() string {
+	return s.name
+}
+
+// Address returns the address the host uses to reach the service tcp
+// port, e.g. Address(5432)
+func (s *Service) Address(port int) string {
+	return s.HostAddress(fmt.Sprintf("%d/tcp", port))
+}
+
+// Options to start the service container from its image
+func (s *Service) Options() (*dockertest.RunOptions, error) {
+	return &dockertest.RunOptions{
+		Hostname:     s.hostname,
+		Repository:   s.repository,
+		Tag:          s.tag,
+		Env:          s.env,
+		Entrypoint:   s.entrypoint,
+		Cmd:          s.cmd,
+		ExposedPorts: s.exposed,
+		PortBindings: s.bindings,
+		ExtraHosts:   s.extraHosts,
+		CapAdd:       s.capAdd,
+		SecurityOpt:  s.securityOpt,
+		DNS:          s.dns,
+		WorkingDir:   s.workingDir,
+		Labels:       copyLabels(s.labels),
+		Privileged:   s.privileged,
+	}, nil
+}
+
+// AfterStart executes the AfterStart of the service params, if any
+func (s *Service) AfterStart(ctx context.Context, r *dockertest.Resource) error {
+	if s.afterStart != nil {
+		return s.afterStart(ctx, s)
+	}
+	return nil
+}
+
+// DependsOn returns the services in the depends_on of the service
+func (s *Service) DependsOn() []goit.Container {
+	deps := make([]goit.Container, 0, len(s.dependsOn))
+	for _, d := range s.dependsOn {
+		deps = append(deps, d.container())
+	}
+	return deps
+}
+
+// Aliases returns the aliases of the service in its networks, they are
+// added to the environment network
+func (s *Service) Aliases() []string {
+	return s.aliases
+}
+
+// WaitStrategy executes the service healthcheck until it succeeds
+func (s *Service) WaitStrategy() wait.Strategy {
+	return s.waitFor
+}
+
+// Mounts returns the volumes and tmpfs of the service
+func (s *Service) Mounts() []goit.Mount {
+	return s.mounts
+}
+
+// Resources returns the resource limits of the service
+func (s *Service) Resources() goit.Resources {
+	return s.resources
+}
+
+// PullPolicy returns the pull_policy of the service
+func (s *Service) PullPolicy() goit.PullPolicy {
+	return s.pullPolicy
+}
+
+// ContainerName names the image of the service, e.g. project-app, the
+// container gets a unique name from goit
+func (b *builtService) ContainerName() string {
+	return b.image
+}
+
+// Hostname of the service in the environment network
+func (b *builtService) Hostname() string {
+	return b.hostname
+}
+
+func (b *builtService) DockerFilePath() string {
+	return b.dockerfile
+}
+
+func (b *builtService) Env() []string {
+	return b.env
+}
+
+func (b *builtService) BuildArgs() []docker.BuildArg {
+	return b.args
+}
+
+func (b *builtService) PortBindings() map[docker.Port][]docker.PortBinding {
+	return b.bindings
+}
+
+func newBuild(svc *Service, b *build, project, dir string) (*builtService, error) {
+	file := b.Dockerfile
+	if file == "" {
+		file = "Dockerfile"
+	}
+	if strings.ContainsAny(file, `/\`) {
+		return nil, errors.Errorf("the dockerfile must be in the root of the build context: %s", file)
+	}
+
+	var args []docker.BuildArg
+	for k, v := range values(b.Args, nil) {
+		args = append(args, docker.BuildArg{Name: k, Value: v})
+	}
+	sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
+
+	return &builtService{
+		Service:    svc,
+		image:      strings.ToLower(project + "-" + svc.name),
+		dockerfile: filepath.Join(resolvePath(dir, b.Context), file),
+		args:       args,
+	}, nil
+}
+
+// splitImage splits the image into repository and tag, e.g.
+// localhost:5000/app:1.0
+func splitImage(img string) (repo, tag string, err error) {
+	if strings.Contains(img, "@") {
+		return "", "", errors.Errorf("image digests are not supported: %s", img)
+	}
+
+	i := strings.LastIndex(img, ":")
+	if i < 0 || strings.Contains(img[i:], "/") {
+		return img, "latest", nil
+	}
+	return img[:i], img[i+1:], nil
+}
+
+// serviceEnv returns the env_file variables overridden by the environment,
+// variables without value are taken from the host
+func serviceEnv(s *service, dir string, lookup func(string) (string, bool)) ([]string, error) {
+	env := map[string]string{}
+	for _, f := range s.EnvFile {
+		vs, err := spec.ReadEnvFile(resolvePath(dir, f))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read env_file: %s", f)
+		}
+		for k, v := range vs {
+			env[k] = v
+		}
+	}
+	for k, v := range values(s.Environment, lookup) {
+		env[k] = v
+	}
+
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// values returns the dict values, the missing ones are taken from lookup
+func values(d dict, lookup func(string) (string, bool)) map[string]string {
+	if len(d) == 0 {
+		return nil
+	}
+
+	m := map[string]string{}
+	for k, v := range d {
+		switch {
+		case v != nil:
+			m[k] = *v
+		case lookup != nil:
+			if hv, ok := lookup(k); ok {
+				m[k] = hv
+			}
+		}
+	}
+	return m
+}
+
+func copyLabels(l map[string]string) map[string]string {
+	if l == nil {
+		return nil
+	}
+
+	c := make(map[string]string, len(l))
+	for k, v := range l {
+		c[k] = v
+	}
+	return c
+}
+
+// serviceMounts returns the volumes and tmpfs of the service, named
+// volumes are prefixed by the project name unless they are external
+func serviceMounts(s *service, f *file, project, dir string) ([]goit.Mount, error) {
+	var ms []goit.Mount
+	for _, v := range s.Volumes {
+		switch v.Type {
+		case "bind":
+			m := goit.BindMount(resolvePath(dir, v.Source), v.Target)
+			m.ReadOnly = v.ReadOnly
+			ms = append(ms, m)
+		case "volume":
+			m := goit.VolumeMount(volumeName(f, project, v.Source), v.Target)
+			m.ReadOnly = v.ReadOnly
+			ms = append(ms, m)
+		case "tmpfs":
+			ms = append(ms, goit.TmpfsMount(v.Target, int64(v.Tmpfs.Size)))
+		default:
+			return nil, errors.Errorf("unsupported volume type %q for: %s", v.Type, v.Target)
+		}
+	}
+
+	for _, t := range s.Tmpfs {
+		target, size := t, int64(0)
+		if i := strings.Index(t, ":"); i >= 0 {
+			target = t[:i]
+			for _, o := range strings.Split(t[i+1:], ",") {
+				if strings.HasPrefix(o, "size=") {
+					n, err := spec.ParseSize(strings.TrimPrefix(o, "size="))
+					if err != nil {
+						return nil, err
+					}
+					size = n
+				}
+			}
+		}
+		ms = append(ms, goit.TmpfsMount(target, size))
+	}
+	return ms, nil
+}
+
+// volumeName returns the docker name of a compose volume
+func volumeName(f *file, project, name string) string {
+	if name == "" {
+		return ""
+	}
+
+	v := f.Volumes[name]
+	switch {
+	case v != nil && v.Name != "":
+		return v.Name
+	case v != nil && v.External:
+		return name
+	}
+	return project + "_" + name
+}
+
+// resolvePath returns the absolute path of a path relative to the
+// compose file directory
+func resolvePath(dir, p string) string {
+	if strings.HasPrefix(p, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// serviceResources returns the resource limits of the service, deploy
+// limits are used when the service limits aren't set
+func serviceResources(s *service) (goit.Resources, error) {
+	limits := s.Deploy.Resources.Limits
+	r := goit.Resources{
+		Memory:    int64(s.MemLimit),
+		CPUShares: s.CPUShares,
+		CPUs:      s.CPUs,
+		CPUQuota:  s.CPUQuota,
+		CPUPeriod: s.CPUPeriod,
+		PidsLimit: s.PidsLimit,
+		ShmSize:   int64(s.ShmSize),
+	}
+	if r.Memory == 0 {
+		r.Memory = int64(limits.Memory)
+	}
+	if r.PidsLimit == 0 {
+		r.PidsLimit = limits.Pids
+	}
+	if r.CPUs == 0 && limits.CPUs != "" {
+		cpus, err := strconv.ParseFloat(limits.CPUs, 64)
+		if err != nil {
+			return r, errors.Wrapf(err, "invalid cpus limit: %s", limits.CPUs)
+		}
+		r.CPUs = cpus
+	}
+
+	for name, u := range s.Ulimits {
+		r.Ulimits = append(r.Ulimits, docker.ULimit{Name: name, Soft: u.Soft, Hard: u.Hard})
+	}
+	sort.Slice(r.Ulimits, func(i, j int) bool { return r.Ulimits[i].Name < r.Ulimits[j].Name })
+	return r, nil
+}
+
+// pullPolicy returns the goit policy of a compose pull_policy
+func pullPolicy(p string) (goit.PullPolicy, error) {
+	switch p {
+	case "", "build":
+		return "", nil
+	case "always":
+		return goit.PullAlways, nil
+	case "never":
+		return goit.PullNever, nil
+	case "missing", "if_not_present":
+		return goit.PullIfNotPresent, nil
+	}
+	return "", errors.Errorf("invalid pull_policy: %s", p)
+}
+
+// healthStrategy executes the healthcheck test until it succeeds, nil
+// when the service has no healthcheck
+func healthStrategy(h *healthcheck) wait.Strategy {
+	if h == nil || h.Disable || len(h.Test) == 0 {
+		return nil
+	}
+
+	var cmd []string
+	switch h.Test[0] {
+	case "NONE":
+		return nil
+	case "CMD":
+		cmd = h.Test[1:]
+	case "CMD-SHELL":
+		cmd = []string{"sh", "-c", strings.Join(h.Test[1:], " ")}
+	default:
+		cmd = h.Test
+	}
+
+	var opts []wait.Option
+	interval := time.Duration(h.Interval)
+	if interval > 0 {
+		opts = append(opts, wait.WithInterval(interval), wait.WithMaxInterval(interval))
+	}
+
+	timeout := time.Duration(h.StartPeriod) + time.Duration(h.Retries)*(interval+time.Duration(h.Timeout))
+	if timeout < minHealthTimeout {
+		timeout = minHealthTimeout
+	}
+	opts = append(opts, wait.WithTimeout(timeout))
+
+	return wait.ForExec(cmd, opts...)
+}
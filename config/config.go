@@ -0,0 +1,580 @@
+// Package config starts the environment described by a goit.yaml file,
+// so TestMain shrinks to one call, e.g.
+//
+//	func TestMain(m *testing.M) {
+//		config.Main(m, "goit.yaml")
+//	}
+//
+// The file maps onto the params of the goit modules:
+//
+//	options:
+//	  reuse: true
+//	  pull_policy: if-not-present
+//	  resources:
+//	    memory: 512m
+//	containers:
+//	  db:
+//	    module: postgres
+//	    image: postgres:${POSTGRES_TAG:-13}
+//	    user: app
+//	    password: secret
+//	    database: app
+//	    migrations: ./migrations
+//	  broker:
+//	    module: kafka
+//	    topics: [orders]
+//	  aws:
+//	    module: aws
+//	    region: eu-central-1
+//	    sqs_queues: [events]
+//	  app:
+//	    module: dockerfile
+//	    dockerfile: ./Dockerfile
+//	    ports:
+//	      8080/tcp: 8080
+//	    depends_on: [db, broker]
+//
+// Environment variables override the values with ${VAR}, ${VAR:-default}
+// or ${VAR:?error}, the .env file next to the goit.yaml is read too, and
+// GOIT_CONFIG replaces the path of the file. Relative paths are resolved
+// from the directory of the file.
+//
+// The goit command starts the same environment outside go test with goit
+// up, the test runs loading the same file attach to its containers
+// instead of starting new ones, see UpName.
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit"
+	"github.com/tclemos/goit/aws"
+	"github.com/tclemos/goit/dockerfile"
+	"github.com/tclemos/goit/internal/spec"
+	"github.com/tclemos/goit/kafka"
+	"github.com/tclemos/goit/postgres"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// DefaultFile is the file loaded when no path is given
+	DefaultFile = "goit.yaml"
+
+	// envConfig replaces the path of the file
+	envConfig = "GOIT_CONFIG"
+)
+
+// Environment is a loaded goit.yaml
+type Environment struct {
+	// Options of the environment, they can be changed before it starts
+	Options goit.Options
+
+	containers map[string]goit.Container
+	names      []string
+}
+
+// Main loads the file, starts its containers, runs the tests and stops
+// the containers, the process exits when the file can't be loaded
+func Main(m *testing.M, path string) {
+	MustLoad(path).Main(m)
+}
+
+// MustLoad is like Load but exits the process when the file can't be
+// loaded, e.g. to keep the environment for the tests
+//
+//	var env *config.Environment
+//
+//	func TestMain(m *testing.M) {
+//		env = config.MustLoad("goit.yaml")
+//		env.Main(m)
+//	}
+func MustLoad(path string) *Environment {
+	env, err := Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load goit config: %v\n", err)
+		os.Exit(1)
+	}
+	return env
+}
+
+// Main starts the containers, runs the tests and stops the containers
+func (e *Environment) Main(m *testing.M) {
+	goit.Main(m, e.Options, e.Containers()...)
+}
+
+// Load reads the file and creates its containers, an empty path loads
+// GOIT_CONFIG or goit.yaml
+func Load(path string) (*Environment, error) {
+	if path == "" {
+		path = os.Getenv(envConfig)
+	}
+	if path == "" {
+		path = DefaultFile
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(path)
+
+	f, err := parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	opt, err := f.Options.options(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+	opt.Attach = UpName(path)
+
+	env := &Environment{Options: opt, containers: map[string]goit.Container{}}
+	for n := range f.Containers {
+		env.names = append(env.names, n)
+	}
+	sort.Strings(env.names)
+
+	// the containers are created after their dependencies, which are part
+	// of their params
+	creating := map[string]bool{}
+	var create func(n string) error
+	create = func(n string) error {
+		if _, ok := env.containers[n]; ok {
+			return nil
+		}
+		if creating[n] {
+			return errors.Errorf("dependency cycle on container: %s", n)
+		}
+		creating[n] = true
+
+		c := f.Containers[n]
+		var deps []goit.Container
+		for _, d := range c.DependsOn {
+			if _, ok := f.Containers[d]; !ok {
+				return errors.Errorf("container %s depends on unknown container: %s", n, d)
+			}
+			if err := create(d); err != nil {
+				return err
+			}
+			deps = append(deps, env.containers[d])
+		}
+
+		gc, err := c.container(n, dir, deps)
+		if err != nil {
+			return errors.Wrapf(err, "invalid container: %s", n)
+		}
+		env.containers[n] = gc
+		return nil
+	}
+	for _, n := range env.names {
+		if err := create(n); err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+// UpName returns the name goit up gives to the environment of the file,
+// the tests loading the same file attach to it: the directory of the file
+// and a hash of its path, so files in directories with the same name
+// don't share environments
+func UpName(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	h := sha256.Sum256([]byte(abs))
+	return strings.ToLower(filepath.Base(filepath.Dir(abs))) + "-" + hex.EncodeToString(h[:4])
+}
+
+// Containers returns the containers of the file sorted by name, goit
+// starts the dependencies first
+func (e *Environment) Containers() []goit.Container {
+	cs := make([]goit.Container, 0, len(e.names))
+	for _, n := range e.names {
+		cs = append(cs, e.containers[n])
+	}
+	return cs
+}
+
+// Container returns the container with the name, or nil if it doesn't exist
+func (e *Environment) Container(name string) goit.Container {
+	return e.containers[name]
+}
+
+// Postgres returns the postgres container with the name, or nil
+func (e *Environment) Postgres(name string) *postgres.Container {
+	c, _ := e.containers[name].(*postgres.Container)
+	return c
+}
+
+// Kafka returns the kafka container with the name, or nil
+func (e *Environment) Kafka(name string) *kafka.Container {
+	c, _ := e.containers[name].(*kafka.Container)
+	return c
+}
+
+// AWS returns the localstack container with the name, or nil
+func (e *Environment) AWS(name string) *aws.Container {
+	c, _ := e.containers[name].(*aws.Container)
+	return c
+}
+
+// Dockerfile returns the dockerfile container with the name, or nil
+func (e *Environment) Dockerfile(name string) *dockerfile.Container {
+	c, _ := e.containers[name].(*dockerfile.Container)
+	return c
+}
+
+// Vars returns the connection details of the started containers as
+// environment variables sorted by name, prefixed by the container name,
+// e.g. DB_URL for the postgres container db
+func (e *Environment) Vars() []string {
+	var vars []string
+	for _, n := range e.names {
+		prefix := varName(n)
+		switch c := e.containers[n].(type) {
+		case *postgres.Container:
+			u := c.Url()
+			vars = append(vars, prefix+"_URL="+u.String())
+		case *kafka.Container:
+			vars = append(vars, prefix+"_BOOTSTRAP_SERVERS="+c.BootstrapServers())
+		case *aws.Container:
+			vars = append(vars, prefix+"_ENDPOINT="+c.ServiceEndpoint())
+		case *dockerfile.Container:
+			for _, port := range publishedPorts(c) {
+				num := strings.Split(port, "/")[0]
+				vars = append(vars, prefix+"_ADDR_"+num+"="+c.HostAddress(port))
+			}
+		}
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// publishedPorts returns the container ports published on the host by
+// the running container, most are chosen by docker when it starts
+func publishedPorts(c goit.Container) []string {
+	h := goit.HandleOf(c)
+	if h == nil {
+		return nil
+	}
+
+	var ports []string
+	for port, bs := range h.Resource().Container.NetworkSettings.Ports {
+		if len(bs) > 0 {
+			ports = append(ports, string(port))
+		}
+	}
+	return ports
+}
+
+// varName returns the container name as an environment variable name
+func varName(n string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, n)
+}
+
+// file is the content of a goit.yaml
+type file struct {
+	Options    options               `yaml:"options"`
+	Containers map[string]*container `yaml:"containers"`
+}
+
+type options struct {
+	AutoRemove         *bool     `yaml:"auto_remove"`
+	Restart            bool      `yaml:"restart"`
+	ExpireAfterSeconds *uint     `yaml:"expire_after_seconds"`
+	Reuse              bool      `yaml:"reuse"`
+	Recreate           bool      `yaml:"recreate"`
+	RestoreSnapshots   bool      `yaml:"restore_snapshots"`
+	PullPolicy         string    `yaml:"pull_policy"`
+	Offline            bool      `yaml:"offline"`
+	DisableReaper      bool      `yaml:"disable_reaper"`
+	ReaperImage        string    `yaml:"reaper_image"`
+	LogsDir            string    `yaml:"logs_dir"`
+	MaxParallelism     int       `yaml:"max_parallelism"`
+	Resources          resources `yaml:"resources"`
+}
+
+type container struct {
+	Module      string            `yaml:"module"`
+	Image       string            `yaml:"image"`
+	Alias       string            `yaml:"alias"`
+	Env         map[string]string `yaml:"env"`
+	DependsOn   []string          `yaml:"depends_on"`
+	SnapshotKey string            `yaml:"snapshot_key"`
+	PullPolicy  string            `yaml:"pull_policy"`
+	Resources   resources         `yaml:"resources"`
+
+	// postgres, Port is also used by aws
+	Port       int    `yaml:"port"`
+	User       string `yaml:"user"`
+	Password   string `yaml:"password"`
+	Database   string `yaml:"database"`
+	Migrations string `yaml:"migrations"`
+
+	// kafka
+	BrokerPort int      `yaml:"broker_port"`
+	ClientPort int      `yaml:"client_port"`
+	ClientID   string   `yaml:"client_id"`
+	Topics     []string `yaml:"topics"`
+
+	// aws
+	Region    string   `yaml:"region"`
+	SqsQueues []string `yaml:"sqs_queues"`
+
+	// dockerfile
+	Dockerfile string            `yaml:"dockerfile"`
+	BuildArgs  map[string]string `yaml:"build_args"`
+	Ports      map[string]string `yaml:"ports"`
+}
+
+type resources struct {
+	Memory    string  `yaml:"memory"`
+	CPUs      float64 `yaml:"cpus"`
+	CPUShares int64   `yaml:"cpu_shares"`
+	PidsLimit int64   `yaml:"pids_limit"`
+	ShmSize   string  `yaml:"shm_size"`
+}
+
+// parseFile reads and interpolates the file, unknown keys are errors so
+// typos don't go unnoticed
+func parseFile(path string) (*file, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read goit config: %s", path)
+	}
+
+	dotEnv, err := spec.ReadEnvFile(filepath.Join(filepath.Dir(path), ".env"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read .env file")
+	}
+	s, err := spec.Interpolate(string(b), func(k string) (string, bool) {
+		if v, ok := os.LookupEnv(k); ok {
+			return v, true
+		}
+		v, ok := dotEnv[k]
+		return v, ok
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to interpolate goit config: %s", path)
+	}
+
+	var f file
+	if err := yaml.UnmarshalStrict([]byte(s), &f); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse goit config: %s", path)
+	}
+	return &f, nil
+}
+
+func (o options) options(dir string) (goit.Options, error) {
+	opt := goit.DefaultOptions()
+	if o.AutoRemove != nil {
+		opt.AutoRemoveContainers = *o.AutoRemove
+	}
+	if o.ExpireAfterSeconds != nil {
+		opt.ExpireContainersAfterSeconds = *o.ExpireAfterSeconds
+	}
+	opt.RestartContainers = o.Restart
+	opt.Reuse = o.Reuse
+	opt.Recreate = o.Recreate
+	opt.RestoreSnapshots = o.RestoreSnapshots
+	opt.Offline = o.Offline
+	opt.DisableReaper = o.DisableReaper
+	opt.ReaperImage = o.ReaperImage
+	opt.MaxParallelism = o.MaxParallelism
+	if o.LogsDir != "" {
+		opt.LogsDir = resolvePath(dir, o.LogsDir)
+	}
+
+	var err error
+	if opt.PullPolicy, err = pullPolicy(o.PullPolicy); err != nil {
+		return opt, err
+	}
+	opt.Resources, err = o.Resources.resources()
+	return opt, err
+}
+
+// container creates the goit container of the module
+func (c *container) container(name, dir string, deps []goit.Container) (goit.Container, error) {
+	cp, err := c.params(deps)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Module {
+	case "postgres":
+		p := postgres.Params{
+			ContainerParams: cp,
+			Port:            c.Port,
+			User:            c.User,
+			Password:        c.Password,
+			Database:        c.Database,
+		}
+		if c.Migrations != "" {
+			p.MigrationsPath = resolvePath(dir, c.Migrations)
+		}
+		return postgres.NewContainer(p), nil
+
+	case "kafka":
+		return kafka.NewContainer(kafka.Params{
+			ContainerParams: cp,
+			BrokerPort:      c.BrokerPort,
+			ClientPort:      c.ClientPort,
+			ClientId:        c.ClientID,
+			Topics:          c.Topics,
+		}), nil
+
+	case "aws":
+		var queues []aws.SqsQueue
+		for _, q := range c.SqsQueues {
+			queues = append(queues, aws.SqsQueue{Name: q})
+		}
+		return aws.NewContainer(aws.Params{
+			ContainerParams: cp,
+			Region:          c.Region,
+			Port:            c.Port,
+			SqsQueues:       queues,
+		}), nil
+
+	case "dockerfile":
+		return c.dockerfile(name, dir, cp)
+
+	case "":
+		return nil, errors.New("module is required")
+	}
+	return nil, errors.Errorf("unknown module: %s", c.Module)
+}
+
+// params returns the params shared by all modules
+func (c *container) params(deps []goit.Container) (goit.ContainerParams, error) {
+	cp := goit.ContainerParams{
+		Alias:       c.Alias,
+		DependsOn:   deps,
+		SnapshotKey: c.SnapshotKey,
+	}
+	if c.Image != "" {
+		cp.Repository, cp.Tag = splitImage(c.Image)
+	}
+
+	keys := make([]string, 0, len(c.Env))
+	for k := range c.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cp.Env = append(cp.Env, k+"="+c.Env[k])
+	}
+
+	var err error
+	if cp.PullPolicy, err = pullPolicy(c.PullPolicy); err != nil {
+		return cp, err
+	}
+	cp.Resources, err = c.Resources.resources()
+	return cp, err
+}
+
+func (c *container) dockerfile(name, dir string, cp goit.ContainerParams) (goit.Container, error) {
+	if c.Dockerfile == "" {
+		return nil, errors.New("dockerfile is required")
+	}
+	if c.Image != "" {
+		return nil, errors.New("image is not supported by dockerfile containers, the image is built from the dockerfile")
+	}
+	if c.Alias != "" {
+		return nil, errors.New("alias is not supported by dockerfile containers, they are reached by their name")
+	}
+
+	var args []docker.BuildArg
+	for k, v := range c.BuildArgs {
+		args = append(args, docker.BuildArg{Name: k, Value: v})
+	}
+	sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
+
+	bindings := map[docker.Port][]docker.PortBinding{}
+	for port, hostPort := range c.Ports {
+		if !strings.Contains(port, "/") {
+			port += "/tcp"
+		}
+		if _, err := strconv.Atoi(hostPort); err != nil {
+			return nil, errors.Errorf("invalid host port for %s: %s", port, hostPort)
+		}
+		bindings[docker.Port(port)] = []docker.PortBinding{{HostIP: "0.0.0.0", HostPort: hostPort}}
+	}
+
+	return dockerfile.NewContainer(dockerfile.Params{
+		ContainerName:  name,
+		DockerFilePath: resolvePath(dir, c.Dockerfile),
+		Env:            c.Env,
+		BuildArgs:      args,
+		PortBindings:   bindings,
+		DependsOn:      cp.DependsOn,
+		SnapshotKey:    cp.SnapshotKey,
+		Resources:      cp.Resources,
+		PullPolicy:     cp.PullPolicy,
+	}), nil
+}
+
+func (r resources) resources() (goit.Resources, error) {
+	res := goit.Resources{
+		CPUs:      r.CPUs,
+		CPUShares: r.CPUShares,
+		PidsLimit: r.PidsLimit,
+	}
+
+	var err error
+	if r.Memory != "" {
+		if res.Memory, err = spec.ParseSize(r.Memory); err != nil {
+			return res, err
+		}
+	}
+	if r.ShmSize != "" {
+		res.ShmSize, err = spec.ParseSize(r.ShmSize)
+	}
+	return res, err
+}
+
+func pullPolicy(p string) (goit.PullPolicy, error) {
+	switch pp := goit.PullPolicy(p); pp {
+	case "", goit.PullAlways, goit.PullNever, goit.PullIfNotPresent:
+		return pp, nil
+	}
+	return "", errors.Errorf("invalid pull_policy: %s", p)
+}
+
+// splitImage splits the image into repository and tag, the tag is empty
+// when the image has none, so the module default is used
+func splitImage(img string) (repo, tag string) {
+	i := strings.LastIndex(img, ":")
+	if i < 0 || strings.Contains(img[i:], "/") {
+		return img, ""
+	}
+	return img[:i], img[i+1:]
+}
+
+// resolvePath returns the absolute path of a path relative to the file
+func resolvePath(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
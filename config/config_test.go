@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tclemos/goit"
+)
+
+func TestLoad(t *testing.T) {
+	setenv(t, "POSTGRES_TAG", "14")
+
+	env, err := Load("testdata/goit.yaml")
+	if err != nil {
+		t.Fatalf("failed to load goit config: %v", err)
+	}
+
+	if !env.Options.Reuse || env.Options.PullPolicy != goit.PullIfNotPresent {
+		t.Errorf("invalid options: %+v", env.Options)
+	}
+	if env.Options.Resources.Memory != 512*1024*1024 {
+		t.Errorf("expected 512m of memory, found: %d", env.Options.Resources.Memory)
+	}
+	if !filepath.IsAbs(env.Options.LogsDir) || filepath.Base(env.Options.LogsDir) != "logs" {
+		t.Errorf("expected the logs dir relative to the file, found: %s", env.Options.LogsDir)
+	}
+	if env.Options.Attach != UpName("testdata/goit.yaml") {
+		t.Errorf("expected to attach to the goit up environment of the file, found: %s", env.Options.Attach)
+	}
+	if UpName("testdata/goit.yaml") == UpName("../compose/testdata/goit.yaml") {
+		t.Errorf("expected files in directories with the same name to have different environments")
+	}
+	if cs := env.Containers(); len(cs) != 4 {
+		t.Fatalf("expected 4 containers, found: %d", len(cs))
+	}
+
+	db := env.Postgres("db")
+	if db == nil {
+		t.Fatal("expected a postgres container")
+	}
+	o, err := db.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Repository != "postgres" || o.Tag != "14" {
+		t.Errorf("expected the tag from the environment, found: %s:%s", o.Repository, o.Tag)
+	}
+
+	if !contains(o.Env, "POSTGRES_DB=orders") {
+		t.Errorf("expected the database from the .env file, found: %v", o.Env)
+	}
+	if env.AWS("aws") == nil {
+		t.Error("expected an aws container")
+	}
+
+	app := env.Dockerfile("app")
+	if app == nil {
+		t.Fatal("expected a dockerfile container")
+	}
+	if len(app.DependsOn()) != 2 {
+		t.Errorf("expected 2 dependencies, found: %d", len(app.DependsOn()))
+	}
+	if app.DependsOn()[0] != env.Container("db") {
+		t.Errorf("expected the dependencies to be the containers of the file")
+	}
+	if app.Resources().CPUs != 0.5 {
+		t.Errorf("expected 0.5 cpus, found: %v", app.Resources().CPUs)
+	}
+	if env.Kafka("app") != nil {
+		t.Errorf("expected no kafka container for a dockerfile one")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := map[string]string{
+		"unknown module": "containers:\n  db:\n    module: mysql\n",
+		"unknown key":    "containers:\n  db:\n    module: postgres\n    tpoics: [a]\n",
+		"cycle":          "containers:\n  a:\n    module: kafka\n    depends_on: [b]\n  b:\n    module: kafka\n    depends_on: [a]\n",
+		"unknown dep":    "containers:\n  a:\n    module: kafka\n    depends_on: [b]\n",
+		"pull policy":    "options:\n  pull_policy: sometimes\n",
+		"required var":   "containers:\n  a:\n    module: kafka\n    image: ${GOIT_TEST_UNSET:?required}\n",
+		"build image":    "containers:\n  app:\n    module: dockerfile\n    dockerfile: Dockerfile\n    image: app:1.0\n",
+		"build alias":    "containers:\n  app:\n    module: dockerfile\n    dockerfile: Dockerfile\n    alias: api\n",
+	}
+
+	dir := t.TempDir()
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(name, " ", "_")+".yaml")
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func contains(env []string, s string) bool {
+	for _, e := range env {
+		if strings.Contains(e, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func setenv(t *testing.T, key, value string) {
+	prev, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestVarName(t *testing.T) {
+	for n, want := range map[string]string{"db": "DB", "order-db": "ORDER_DB", "app.v2": "APP_V2"} {
+		if got := varName(n); got != want {
+			t.Errorf("expected %s for %s, found: %s", want, n, got)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
+	"github.com/tclemos/goit/wait"
 )
 
 // We need these public variables to share information betwee
@@ -23,6 +24,24 @@ type Container interface {
 	AfterStart(context.Context, *dockertest.Resource) error
 }
 
+// containerWithDependencies represents a docker container that can only
+// be started after other containers are ready
+type containerWithDependencies interface {
+	Container
+
+	// Containers that must be started before this one
+	DependsOn() []Container
+}
+
+// containerWithWaitStrategy represents a docker container that tells
+// goit how to wait until it is ready, before AfterStart is executed
+type containerWithWaitStrategy interface {
+	Container
+
+	// Strategy to wait for the container, nil means no wait
+	WaitStrategy() wait.Strategy
+}
+
 // ContainerFromRepository represents a docker container that will be
 // created based on an docker image from a docker repository
 type containerFromRepository interface {
@@ -53,10 +72,104 @@ type containerFromDockerFile interface {
 	PortBindings() map[docker.Port][]docker.PortBinding
 }
 
+// containerWithHostname represents a dockerfile container reached by a
+// hostname in the environment network, its ContainerName only names its
+// image and goit gives the docker container a unique name, so environments
+// starting it at the same time don't collide
+type containerWithHostname interface {
+	containerFromDockerFile
+
+	// Hostname other containers use to reach this one
+	Hostname() string
+}
+
 type ContainerParams struct {
 	Repository string
 	Tag        string
 	Env        []string
+
+	// Alias is the name other containers use to reach this one in the
+	// environment network, each module provides a default
+	Alias string
+
+	// DependsOn lists the containers that must be started before this one
+	DependsOn []Container
+
+	// WaitFor replaces the module strategy to wait until the container is ready
+	WaitFor wait.Strategy
+
+	// Files are copied into the container before it starts
+	Files []File
+
+	// Mounts are bind mounts, volumes and tmpfs mounted in the container
+	Mounts []Mount
+
+	// SnapshotKey enables snapshots of the container, it identifies the
+	// content seeded into it, e.g. the migrations version, see Snapshot
+	SnapshotKey string
+
+	// Resources limit what the container can use, they override the
+	// environment defaults
+	Resources Resources
+
+	// PullPolicy tells when the image is pulled, empty uses the
+	// environment policy
+	PullPolicy PullPolicy
+
+	// Auth authenticates the image pull, by default the credentials of
+	// docker login are used, see the docker config.json
+	Auth docker.AuthConfiguration
+}
+
+// Spec exposes the settings of ContainerParams to goit, embed it in a
+// Container with the Spec of its params, so goit starts it with their
+// dependencies, files, mounts, resources, pull policy and snapshot key,
+// e.g.
+//
+//	type Container struct {
+//		goit.Handle
+//		goit.Spec
+//		...
+//	}
+//
+//	c := &Container{Spec: p.Spec(), ...}
+type Spec struct {
+	params ContainerParams
+}
+
+// Spec returns the Spec of the params, to be embedded in a Container
+func (p ContainerParams) Spec() Spec {
+	return Spec{params: p}
+}
+
+// DependsOn returns the containers that must be started before this one
+func (s Spec) DependsOn() []Container {
+	return s.params.DependsOn
+}
+
+// Files returns the files copied into the container before it starts
+func (s Spec) Files() []File {
+	return s.params.Files
+}
+
+// Mounts returns the storage mounted in the container
+func (s Spec) Mounts() []Mount {
+	return s.params.Mounts
+}
+
+// Resources returns the resource limits of the container
+func (s Spec) Resources() Resources {
+	return s.params.Resources
+}
+
+// PullPolicy returns when the image of the container is pulled
+func (s Spec) PullPolicy() PullPolicy {
+	return s.params.PullPolicy
+}
+
+// SnapshotKey returns the key identifying the snapshots of the container
+func (s Spec) SnapshotKey() string {
+	return s.params.SnapshotKey
 }
 
 func (p ContainerParams) GetRepoTag(defaultRepo, defaultTag string) (repo, tag string) {
@@ -75,6 +188,13 @@ func (p ContainerParams) GetRepoTag(defaultRepo, defaultTag string) (repo, tag s
 	return repo, tag
 }
 
+func (p ContainerParams) GetAlias(defaultAlias string) string {
+	if strings.TrimSpace(p.Alias) != "" {
+		return p.Alias
+	}
+	return defaultAlias
+}
+
 func (p ContainerParams) MergeEnv(env []string) []string {
 	envMap := map[string]string{}
 	getEnvKeyValue := func(s string) (string, string) {
@@ -0,0 +1,42 @@
+package goit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ory/dockertest/v3"
+)
+
+// specContainer declares its settings through an embedded Spec
+type specContainer struct {
+	Handle
+	Spec
+}
+
+func (c *specContainer) AfterStart(context.Context, *dockertest.Resource) error {
+	return nil
+}
+
+func TestSpec(t *testing.T) {
+	dep := &fakeContainer{name: "db"}
+	p := ContainerParams{
+		DependsOn:   []Container{dep},
+		Files:       []File{{Source: FromBytes([]byte("x"), 0644), Target: "/x"}},
+		Mounts:      []Mount{TmpfsMount("/tmp", 0)},
+		SnapshotKey: "v1",
+		Resources:   Resources{Memory: 1 << 20},
+		PullPolicy:  PullNever,
+	}
+	c := &specContainer{Spec: p.Spec()}
+
+	if deps := dependenciesOf(c); len(deps) != 1 || deps[0] != Container(dep) {
+		t.Errorf("expected the dependencies, found: %v", deps)
+	}
+	if !reflect.DeepEqual(filesOf(c), p.Files) || !reflect.DeepEqual(mountsOf(c), p.Mounts) {
+		t.Errorf("expected the files and mounts, found: %v, %v", filesOf(c), mountsOf(c))
+	}
+	if snapshotKeyOf(c) != "v1" || resourcesOf(c).Memory != 1<<20 || pullPolicyOf(c, Options{}) != PullNever {
+		t.Errorf("expected the snapshot key, resources and pull policy of the params")
+	}
+}
@@ -0,0 +1,391 @@
+package goit
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+)
+
+// CopySource is the content of a file or directory copied into a container
+type CopySource interface {
+	// archive writes the content to the tar archive at the target path
+	archive(tw *tar.Writer, target string) error
+}
+
+// File is copied into the container after it is created and before it
+// starts, e.g. fixtures and configuration read during the boot
+type File struct {
+	Source CopySource
+
+	// Target is the absolute path of the file or directory in the container
+	Target string
+}
+
+// containerWithFiles represents a docker container that receives files
+// before it starts
+type containerWithFiles interface {
+	Container
+
+	// Files copied into the container before it starts
+	Files() []File
+}
+
+// FromHostPath copies a file or a directory, with its content, from the host
+func FromHostPath(p string) CopySource {
+	return hostPath(p)
+}
+
+// FromBytes copies the content as a file with the mode, e.g. 0644
+func FromBytes(content []byte, mode os.FileMode) CopySource {
+	return bytesSource{content: content, mode: mode}
+}
+
+// FromFS copies a file or a directory, with its content, from the file
+// system, e.g. an embed.FS, use "." to copy the whole file system
+func FromFS(fsys fs.FS, p string) CopySource {
+	return fsSource{fsys: fsys, path: p}
+}
+
+// CopyTo copies the source into a container started by goit, the target
+// is the absolute path of the file or directory in the container
+func CopyTo(ctx context.Context, c Container, target string, src CopySource) error {
+	h := HandleOf(c)
+	if h == nil {
+		return errors.Errorf("container is not started: %s", describe(c))
+	}
+	return h.CopyTo(ctx, target, src)
+}
+
+// CopyFrom copies a file or a directory from a container started by goit
+// to the host path
+func CopyFrom(ctx context.Context, c Container, src string, dst string) error {
+	h := HandleOf(c)
+	if h == nil {
+		return errors.Errorf("container is not started: %s", describe(c))
+	}
+	return h.CopyFrom(ctx, src, dst)
+}
+
+// ReadFile reads the content of a file from a container started by goit
+func ReadFile(ctx context.Context, c Container, p string) ([]byte, error) {
+	h := HandleOf(c)
+	if h == nil {
+		return nil, errors.Errorf("container is not started: %s", describe(c))
+	}
+	return h.ReadFile(ctx, p)
+}
+
+// CopyTo copies the source into the container, see CopyTo
+func (h *Handle) CopyTo(ctx context.Context, target string, src CopySource) error {
+	p, r := h.Pool(), h.Resource()
+	if p == nil || r == nil {
+		return errors.New("container is not started")
+	}
+	return copyTo(ctx, p, r.Container.ID, File{Source: src, Target: target})
+}
+
+// CopyFrom copies a file or a directory from the container, see CopyFrom
+func (h *Handle) CopyFrom(ctx context.Context, src string, dst string) error {
+	p, r := h.Pool(), h.Resource()
+	if p == nil || r == nil {
+		return errors.New("container is not started")
+	}
+
+	var buf bytes.Buffer
+	err := p.Client.DownloadFromContainer(r.Container.ID, docker.DownloadFromContainerOptions{
+		Context:      ctx,
+		Path:         src,
+		OutputStream: &buf,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to copy from container: %s", src)
+	}
+
+	return extract(tar.NewReader(&buf), dst)
+}
+
+// ReadFile reads the content of a file from the container, see ReadFile
+func (h *Handle) ReadFile(ctx context.Context, p string) ([]byte, error) {
+	pool, r := h.Pool(), h.Resource()
+	if pool == nil || r == nil {
+		return nil, errors.New("container is not started")
+	}
+
+	var buf bytes.Buffer
+	err := pool.Client.DownloadFromContainer(r.Container.ID, docker.DownloadFromContainerOptions{
+		Context:      ctx,
+		Path:         p,
+		OutputStream: &buf,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read from container: %s", p)
+	}
+
+	tr := tar.NewReader(&buf)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read from container: %s", p)
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return nil, errors.Errorf("not a regular file: %s", p)
+	}
+	return io.ReadAll(tr)
+}
+
+// filesOf returns the files copied into the container before it starts
+func filesOf(c Container) []File {
+	if cf, ok := c.(containerWithFiles); ok {
+		return cf.Files()
+	}
+	return nil
+}
+
+// copyTo uploads the files to the container, it can be created or running
+func copyTo(ctx context.Context, p *dockertest.Pool, id string, files ...File) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		if !path.IsAbs(f.Target) {
+			return errors.Errorf("target must be an absolute path: %s", f.Target)
+		}
+		if f.Source == nil {
+			return errors.Errorf("source is missing for: %s", f.Target)
+		}
+
+		target := strings.TrimPrefix(path.Clean(f.Target), "/")
+		if err := f.Source.archive(tw, target); err != nil {
+			return errors.Wrapf(err, "failed to archive: %s", f.Target)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	err := p.Client.UploadToContainer(id, docker.UploadToContainerOptions{
+		Context:     ctx,
+		Path:        "/",
+		InputStream: &buf,
+	})
+	return errors.Wrap(err, "failed to copy to container")
+}
+
+type hostPath string
+
+func (s hostPath) archive(tw *tar.Writer, target string) error {
+	root := string(s)
+	return filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(target, filepath.ToSlash(rel))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+type bytesSource struct {
+	content []byte
+	mode    os.FileMode
+}
+
+func (s bytesSource) archive(tw *tar.Writer, target string) error {
+	mode := s.mode
+	if mode == 0 {
+		mode = 0644
+	}
+
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     target,
+		Mode:     int64(mode.Perm()),
+		Size:     int64(len(s.content)),
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(s.content)
+	return err
+}
+
+type fsSource struct {
+	fsys fs.FS
+	path string
+}
+
+func (s fsSource) archive(tw *tar.Writer, target string) error {
+	return fs.WalkDir(s.fsys, s.path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(target, s.rel(p))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := s.fsys.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// rel returns the path walked relative to the source path, the paths of
+// the whole file system, ".", are already relative, e.g. .env
+func (s fsSource) rel(p string) string {
+	root := path.Clean(s.path)
+	switch {
+	case p == root:
+		return ""
+	case root == ".":
+		return p
+	}
+	return strings.TrimPrefix(p, root+"/")
+}
+
+// extract writes the archive downloaded from a container to the host path,
+// the archive root, a file or a directory, is renamed to the path. Entries
+// under an extracted symlink are rejected, they would be written wherever
+// the symlink points to.
+func extract(tr *tar.Reader, dst string) error {
+	root := ""
+	links := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read archive")
+		}
+
+		name := path.Clean(hdr.Name)
+		if root == "" {
+			root = name
+		}
+		rel, err := entryPath(root, name, links)
+		if err != nil {
+			return errors.Wrapf(err, "invalid archive entry: %s", hdr.Name)
+		}
+		p := filepath.Join(dst, rel)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(p, os.FileMode(hdr.Mode).Perm()|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(p, tr, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, p); err != nil {
+				return err
+			}
+			links[rel] = true
+		case tar.TypeLink:
+			target, err := entryPath(root, path.Clean(hdr.Linkname), links)
+			if err != nil || links[target] {
+				return errors.Errorf("invalid archive hard link: %s to %s", hdr.Name, hdr.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				return err
+			}
+			if err := os.Link(filepath.Join(dst, target), p); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// entryPath returns the path of an archive entry relative to the archive
+// root, entries outside the root or under an extracted symlink are invalid
+func entryPath(root, name string, links map[string]bool) (string, error) {
+	rel, err := filepath.Rel(root, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("entry is outside the archive root")
+	}
+
+	for d := filepath.Dir(rel); d != "." && d != string(filepath.Separator); d = filepath.Dir(d) {
+		if links[d] {
+			return "", errors.Errorf("entry is under the symlink: %s", d)
+		}
+	}
+	return rel, nil
+}
+
+func writeFile(p string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
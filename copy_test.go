@@ -0,0 +1,212 @@
+package goit
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestArchiveExtract(t *testing.T) {
+	src, err := ioutil.TempDir("", "goit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "sub", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := map[string]CopySource{
+		"host":  FromHostPath(src),
+		"fs":    FromFS(fstest.MapFS{"sub/a.txt": {Data: []byte("a"), Mode: 0644}}, "."),
+		"bytes": FromBytes([]byte("a"), 0600),
+	}
+	for name, s := range sources {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			if err := s.archive(tw, "data"); err != nil {
+				t.Fatalf("failed to archive: %v", err)
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			dst, err := ioutil.TempDir("", "goit")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dst)
+
+			out := filepath.Join(dst, "out")
+			if err := extract(tar.NewReader(&buf), out); err != nil {
+				t.Fatalf("failed to extract: %v", err)
+			}
+
+			p := filepath.Join(out, "sub", "a.txt")
+			if name == "bytes" {
+				p = out
+			}
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				t.Fatalf("failed to read the extracted file: %v", err)
+			}
+			if string(b) != "a" {
+				t.Errorf("invalid content, expected a, found: %s", b)
+			}
+		})
+	}
+}
+
+func TestArchiveFSKeepsDotfiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		".env":         {Data: []byte("A=1"), Mode: 0644},
+		".config/x":    {Data: []byte("x"), Mode: 0644},
+		"sub/.hidden":  {Data: []byte("h"), Mode: 0644},
+		"sub/file.txt": {Data: []byte("f"), Mode: 0644},
+	}
+	cases := map[string][]string{
+		".":   {"data", "data/.config", "data/.config/x", "data/.env", "data/sub", "data/sub/.hidden", "data/sub/file.txt"},
+		"sub": {"data", "data/.hidden", "data/file.txt"},
+	}
+
+	for root, want := range cases {
+		t.Run(root, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			if err := FromFS(fsys, root).archive(tw, "data"); err != nil {
+				t.Fatalf("failed to archive: %v", err)
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			tr := tar.NewReader(&buf)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				names = append(names, hdr.Name)
+			}
+			if !reflect.DeepEqual(names, want) {
+				t.Errorf("expected %v, found: %v", want, names)
+			}
+		})
+	}
+}
+
+func TestExtractRejectsEscapingEntries(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, n := range []string{"data", "data/../../evil"} {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: n, Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := ioutil.TempDir("", "goit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+
+	if err := extract(tar.NewReader(&buf), filepath.Join(dst, "out")); err == nil {
+		t.Errorf("expected an error for entries outside the root")
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	outside, err := ioutil.TempDir("", "goit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+
+	tests := map[string]struct {
+		entries []tar.Header
+		valid   bool
+	}{
+		"write through symlink": {
+			entries: []tar.Header{
+				{Typeflag: tar.TypeDir, Name: "data/", Mode: 0755},
+				{Typeflag: tar.TypeSymlink, Name: "data/link", Linkname: outside},
+				{Typeflag: tar.TypeReg, Name: "data/link/evil", Mode: 0644},
+			},
+		},
+		"hard link": {
+			entries: []tar.Header{
+				{Typeflag: tar.TypeDir, Name: "data/", Mode: 0755},
+				{Typeflag: tar.TypeReg, Name: "data/a", Mode: 0644, Size: 5},
+				{Typeflag: tar.TypeLink, Name: "data/b", Linkname: "data/a"},
+			},
+			valid: true,
+		},
+		"hard link outside": {
+			entries: []tar.Header{
+				{Typeflag: tar.TypeDir, Name: "data/", Mode: 0755},
+				{Typeflag: tar.TypeLink, Name: "data/b", Linkname: "etc/passwd"},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, hdr := range tt.entries {
+				hdr := hdr
+				if err := tw.WriteHeader(&hdr); err != nil {
+					t.Fatal(err)
+				}
+				if hdr.Size > 0 {
+					tw.Write([]byte("hello"))
+				}
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			dst, err := ioutil.TempDir("", "goit")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dst)
+
+			err = extract(tar.NewReader(&buf), filepath.Join(dst, "out"))
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("expected the entry to be rejected")
+				}
+				if _, err := os.Stat(filepath.Join(outside, "evil")); err == nil {
+					t.Errorf("expected no file written outside the destination")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unable to extract: %v", err)
+			}
+			b, err := ioutil.ReadFile(filepath.Join(dst, "out", "b"))
+			if err != nil || string(b) != "hello" {
+				t.Errorf("expected the hard link content, found: %q, err: %v", b, err)
+			}
+		})
+	}
+}
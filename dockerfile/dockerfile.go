@@ -7,6 +7,8 @@ import (
 
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
+	"github.com/tclemos/goit"
+	"github.com/tclemos/goit/wait"
 )
 
 // Params needed to start a container from a dockerfile
@@ -17,10 +19,35 @@ type Params struct {
 	BuildArgs      []docker.BuildArg
 	PortBindings   map[docker.Port][]docker.PortBinding
 	AfterStart     func(context.Context, *dockertest.Resource, *map[string]interface{}) error
+
+	// DependsOn lists the containers that must be started before this one
+	DependsOn []goit.Container
+
+	// WaitFor is the strategy to wait until the container is ready,
+	// it is executed before AfterStart
+	WaitFor wait.Strategy
+
+	// Files are copied into the container before it starts
+	Files []goit.File
+
+	// Mounts are bind mounts, volumes and tmpfs mounted in the container
+	Mounts []goit.Mount
+
+	// SnapshotKey enables snapshots of the container, see goit.Snapshot
+	SnapshotKey string
+
+	// Resources limit what the container can use, see goit.Resources
+	Resources goit.Resources
+
+	// PullPolicy tells when the base images are pulled, only
+	// goit.PullAlways changes the docker build behavior
+	PullPolicy goit.PullPolicy
 }
 
 // Container metadata to load a container
 type Container struct {
+	goit.Handle
+	goit.Spec
 	params Params
 	Values map[string]interface{}
 }
@@ -31,7 +58,17 @@ func NewContainer(p Params) *Container {
 		panic("ContainerName is required")
 	}
 
+	spec := goit.ContainerParams{
+		DependsOn:   p.DependsOn,
+		Files:       p.Files,
+		Mounts:      p.Mounts,
+		SnapshotKey: p.SnapshotKey,
+		Resources:   p.Resources,
+		PullPolicy:  p.PullPolicy,
+	}.Spec()
+
 	return &Container{
+		Spec:   spec,
 		params: p,
 	}
 }
@@ -62,6 +99,11 @@ func (c *Container) PortBindings() map[docker.Port][]docker.PortBinding {
 	return c.params.PortBindings
 }
 
+// WaitStrategy returns the strategy to wait until the container is ready
+func (c *Container) WaitStrategy() wait.Strategy {
+	return c.params.WaitFor
+}
+
 // AfterStart will check the connection and execute migrations
 func (c *Container) AfterStart(ctx context.Context, r *dockertest.Resource) error {
 	if c.params.AfterStart != nil {
@@ -0,0 +1,396 @@
+package goit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit/log"
+	"github.com/tclemos/goit/wait"
+)
+
+// Environment is an isolated group of containers that are started and
+// stopped together, use it when a package needs more than one set of
+// containers, e.g. one per test file or subtest
+type Environment struct {
+	opt Options
+	log log.Logger
+
+	mu         sync.Mutex
+	pool       *dockertest.Pool
+	network    *dockertest.Network
+	logCtx     context.Context
+	cancelLogs context.CancelFunc
+	signals    *signalHandler
+	resources  []*dockertest.Resource
+	containers map[Container]*dockertest.Resource
+
+	// starting tracks the startups in progress
+	starting sync.WaitGroup
+
+	// logs tracks the containers output being written to the logger
+	logs sync.WaitGroup
+
+	// volumes created for the containers, removed by Stop
+	volumes volumeSet
+}
+
+// NewEnvironment creates a new instance of Environment
+func NewEnvironment(opt Options) *Environment {
+	l := opt.Logger
+	if l == nil {
+		l = log.Default()
+	}
+
+	return &Environment{
+		opt:        opt,
+		log:        l,
+		containers: map[Container]*dockertest.Resource{},
+	}
+}
+
+// Start the containers of this environment, containers started by
+// previous calls are kept and stopped together by Stop.
+//
+// Containers that don't depend on each other are started concurrently,
+// the ones declaring dependencies wait for them to be ready, dependencies
+// missing from the list are started as well.
+func (e *Environment) Start(ctx context.Context, containers ...Container) {
+	e.start(ctx, e.opt, containers...)
+}
+
+// Stop purges all the containers started by this environment, except the
+// ones kept to be reused
+func (e *Environment) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.stopHandlingSignals()
+
+	if e.cancelLogs != nil {
+		e.cancelLogs()
+		e.logCtx, e.cancelLogs = nil, nil
+	}
+
+	e.release(e.pool, e.network, e.resources)
+	e.logs.Wait()
+	for c := range e.containers {
+		unbindHandle(c)
+	}
+	e.resources = nil
+	e.containers = map[Container]*dockertest.Resource{}
+
+	if vs := e.volumes.take(); len(vs) > 0 {
+		removeVolumes(e.log, e.pool, vs)
+	}
+
+	if e.network != nil {
+		removeNetwork(e.log, e.pool, e.network)
+		e.network = nil
+	}
+}
+
+// Network returns the docker network shared by the containers of this
+// environment, or nil if no container was started yet
+func (e *Environment) Network() *dockertest.Network {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.network
+}
+
+// Resources started by this environment, in the order they were started
+func (e *Environment) Resources() []*dockertest.Resource {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rs := make([]*dockertest.Resource, len(e.resources))
+	copy(rs, e.resources)
+	return rs
+}
+
+// startedContainers returns the containers started by this environment
+func (e *Environment) startedContainers() []Container {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cs := make([]Container, 0, len(e.containers))
+	for c := range e.containers {
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// StartE starts the containers of this environment, if any container
+// fails to start, the containers started by this call are purged, except
+// the reused ones, and a *StartError is returned
+func (e *Environment) StartE(ctx context.Context, containers ...Container) error {
+	return e.startE(ctx, e.opt, containers...)
+}
+
+func (e *Environment) start(ctx context.Context, opt Options, containers ...Container) {
+	if err := e.startE(ctx, opt, containers...); err != nil {
+		e.Stop()
+		panic(err)
+	}
+}
+
+func (e *Environment) startE(ctx context.Context, opt Options, containers ...Container) error {
+	ctx = log.NewContext(ctx, e.log)
+	e.log.Debug("initializing containers")
+	opt = upOptions(opt)
+
+	e.starting.Add(1)
+	defer e.starting.Done()
+
+	e.handleSignals(opt)
+
+	p, err := e.getPool()
+	if err != nil {
+		e.log.Error("failed to create docker pool", "phase", PhaseConnect, "err", err)
+		return asStartError("", PhaseConnect, err)
+	}
+
+	nodes, err := buildGraph(containers, e.isStarted)
+	if err != nil {
+		e.log.Error("failed to resolve container dependencies", "phase", PhaseDependencies, "err", err)
+		return asStartError("", PhaseDependencies, err)
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	if err := startReaper(ctx, p, opt); err != nil {
+		e.log.Warn("failed to start reaper, containers may be left behind if the tests are killed", "err", err)
+	}
+
+	net, err := e.getNetwork(ctx, p, opt)
+	if err != nil {
+		// the network belongs to the whole environment, not to a container
+		return newStartError("", PhaseNetwork, err)
+	}
+	cfg := startConfig{opt: opt, network: net, logCtx: e.getLogCtx(), logs: &e.logs, volumes: &e.volumes}
+	cfg.attach = attachTo(ctx, p, opt)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func(interrupted <-chan struct{}) {
+		select {
+		case <-interrupted:
+			cancel()
+		case <-ctx.Done():
+		}
+	}(e.interrupted())
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		started  = map[Container]*dockertest.Resource{}
+		order    []*dockertest.Resource
+	)
+
+	var sem chan struct{}
+	if opt.MaxParallelism > 0 {
+		sem = make(chan struct{}, opt.MaxParallelism)
+	}
+
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n *node) {
+			defer wg.Done()
+			defer close(n.done)
+
+			for _, d := range n.deps {
+				<-d.done
+				if d.failed {
+					n.failed = true
+					return
+				}
+			}
+
+			if sem != nil {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+			}
+
+			var r *dockertest.Resource
+			err := ctx.Err()
+			if err != nil {
+				err = newStartError(describe(n.c), PhaseRun, err)
+			} else {
+				r, err = startAndInitContainer(ctx, p, n.c, cfg)
+			}
+
+			var se *StartError
+			if r != nil && ctx.Err() == nil && errors.As(err, &se) {
+				// the output tells why it failed, it is gone once purged
+				se.Logs = tailLogs(p, r, failureLogLines)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if r != nil {
+				started[n.c] = r
+				order = append(order, r)
+			}
+			if err != nil {
+				n.failed = true
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		e.release(p, net, order)
+		for c := range started {
+			unbindHandle(c)
+		}
+		return firstErr
+	}
+
+	e.mu.Lock()
+	e.resources = append(e.resources, order...)
+	for c, r := range started {
+		e.containers[c] = r
+	}
+	e.mu.Unlock()
+
+	return nil
+}
+
+// getNetwork returns the network of the environment, creating it on the first call
+func (e *Environment) getNetwork(ctx context.Context, p *dockertest.Pool, opt Options) (*dockertest.Network, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.network != nil {
+		return e.network, nil
+	}
+
+	net, err := createNetwork(ctx, p, upLabels(opt))
+	if err != nil {
+		return nil, err
+	}
+
+	e.network = net
+	return net, nil
+}
+
+// getLogCtx returns the context that ends the log redirects when the environment stops
+func (e *Environment) getLogCtx() context.Context {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.logCtx == nil {
+		e.logCtx, e.cancelLogs = context.WithCancel(log.NewContext(context.Background(), e.log))
+	}
+	return e.logCtx
+}
+
+func (e *Environment) isStarted(c Container) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, ok := e.containers[c]
+	return ok
+}
+
+func (e *Environment) getPool() (*dockertest.Pool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.pool != nil {
+		return e.pool, nil
+	}
+
+	p, err := newPool()
+	if err != nil {
+		return nil, err
+	}
+
+	e.pool = p
+	return p, nil
+}
+
+// release purges the resources, except the ones kept to be reused, which
+// are only disconnected from the environment network
+func (e *Environment) release(p *dockertest.Pool, net *dockertest.Network, rs []*dockertest.Resource) {
+	var purge []*dockertest.Resource
+	for _, r := range rs {
+		if isReusable(r) {
+			e.log.Info("keeping container to be reused", "container", r.Container.Name)
+			if net != nil {
+				disconnectNetwork(e.log, p, net, r)
+			}
+			continue
+		}
+		purge = append(purge, r)
+	}
+	e.purge(p, purge)
+}
+
+// purge removes the resources from docker, logging the failures
+func (e *Environment) purge(p *dockertest.Pool, rs []*dockertest.Resource) {
+	for _, r := range rs {
+		e.log.Debug("purging container", "container", r.Container.Name)
+		err := p.Purge(r)
+		if err != nil {
+			e.log.Warn("could not purge container", "container", r.Container.Name, "err", err)
+		} else {
+			e.log.Info("container purged", "container", r.Container.Name)
+		}
+	}
+}
+
+// startAndInitContainer starts the container and executes its AfterStart,
+// the resource is returned even on errors, so it can be purged
+func startAndInitContainer(ctx context.Context, p *dockertest.Pool, c Container, cfg startConfig) (*dockertest.Resource, error) {
+	l := log.FromContext(ctx)
+	r, err := startContainer(ctx, p, c, cfg)
+	if err != nil {
+		return r, err
+	}
+
+	if s := waitStrategyOf(c); s != nil {
+		l.Debug("waiting for container", "container", r.Container.Name, "phase", PhaseWait)
+		if err := s.WaitUntilReady(ctx, HandleOf(c)); err != nil {
+			l.Error("container not ready", "container", r.Container.Name, "phase", PhaseWait, "err", err)
+			return r, newStartError(describe(c), PhaseWait, err)
+		}
+	}
+
+	l.Debug("executing AfterStart", "container", r.Container.Name, "phase", PhaseAfterStart)
+	if err := c.AfterStart(ctx, r); err != nil {
+		l.Error("failed to execute AfterStart", "container", r.Container.Name, "phase", PhaseAfterStart, "err", err)
+		return r, newStartError(describe(c), PhaseAfterStart, err)
+	}
+
+	return r, nil
+}
+
+// waitStrategyOf returns the strategy to wait for the container, if any
+func waitStrategyOf(c Container) wait.Strategy {
+	if cw, ok := c.(containerWithWaitStrategy); ok {
+		return cw.WaitStrategy()
+	}
+	return nil
+}
+
+// startContainer starts the container accordingly to its type
+func startContainer(ctx context.Context, p *dockertest.Pool, c Container, cfg startConfig) (*dockertest.Resource, error) {
+	switch cf := c.(type) {
+	case containerFromDockerFile:
+		return startContainerFromDockerFile(ctx, p, cf, cfg)
+	case containerFromRepository:
+		return startContainerFromRepository(ctx, p, cf, cfg)
+	default:
+		return nil, newStartError(describe(c), PhaseOptions, errors.New("unknown container type"))
+	}
+}
@@ -0,0 +1,60 @@
+package goit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+func TestReleaseKeepsReusedContainers(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client, err := docker.NewClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &dockertest.Pool{Client: client}
+	net := &dockertest.Network{Network: &docker.Network{ID: "net", Name: "goit-net"}}
+
+	reused := &dockertest.Resource{Container: &docker.Container{
+		ID:     "reused",
+		Name:   "/db",
+		Config: &docker.Config{Labels: map[string]string{labelReuseHash: "hash"}},
+	}}
+	started := &dockertest.Resource{Container: &docker.Container{
+		ID:     "started",
+		Name:   "/app",
+		Config: &docker.Config{},
+	}}
+
+	e := NewEnvironment(DefaultOptions())
+	e.release(p, net, []*dockertest.Resource{reused, started})
+
+	want := []string{
+		"POST /networks/net/disconnect",
+		"DELETE /containers/started",
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("expected requests %v, found: %v", want, requests)
+	}
+	for i := range want {
+		if requests[i] != want[i] {
+			t.Errorf("expected requests %v, found: %v", want, requests)
+			break
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package goit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Phase of the container startup in which an error happened
+type Phase string
+
+const (
+	// PhaseConnect is the phase where goit connects to the docker daemon
+	PhaseConnect Phase = "connect"
+
+	// PhaseOptions is the phase where the container options are loaded
+	PhaseOptions Phase = "options"
+
+	// PhaseDependencies is the phase where the dependencies between containers are resolved
+	PhaseDependencies Phase = "dependencies"
+
+	// PhaseNetwork is the phase where the environment network is created
+	PhaseNetwork Phase = "network"
+
+	// PhasePull is the phase where the container image is pulled from the repository
+	PhasePull Phase = "pull"
+
+	// PhaseBuild is the phase where the container image is built from a dockerfile
+	PhaseBuild Phase = "build"
+
+	// PhaseRun is the phase where the container is created and started
+	PhaseRun Phase = "run"
+
+	// PhaseExpire is the phase where the container is set to expire
+	PhaseExpire Phase = "expire"
+
+	// PhaseWait is the phase where goit waits until the container is ready
+	PhaseWait Phase = "wait"
+
+	// PhaseAfterStart is the phase where the container AfterStart is executed
+	PhaseAfterStart Phase = "AfterStart"
+)
+
+// StartError is returned when a container fails to start, it tells
+// which container and which phase of the startup failed, Container is
+// empty when the failure concerns the whole environment, e.g. the docker
+// connection
+type StartError struct {
+	Container string
+	Phase     Phase
+	Err       error
+
+	// Logs are the last lines of the container output, read before the
+	// container is purged, empty when the container wasn't created
+	Logs []string
+}
+
+func newStartError(container string, phase Phase, err error) *StartError {
+	return &StartError{
+		Container: container,
+		Phase:     phase,
+		Err:       err,
+	}
+}
+
+// asStartError returns the error as a *StartError, wrapping it unless it
+// already is one
+func asStartError(container string, phase Phase, err error) error {
+	var se *StartError
+	if errors.As(err, &se) {
+		return err
+	}
+	return newStartError(container, phase, err)
+}
+
+func (e *StartError) Error() string {
+	if e.Container == "" {
+		return fmt.Sprintf("environment failed during %s: %v", e.Phase, e.Err)
+	}
+
+	msg := fmt.Sprintf("container %s failed during %s: %v", e.Container, e.Phase, e.Err)
+	if len(e.Logs) > 0 {
+		msg += fmt.Sprintf("\nlast %d lines of %s:\n%s", len(e.Logs), e.Container, strings.Join(e.Logs, "\n"))
+	}
+	return msg
+}
+
+// Unwrap returns the error that caused the container to fail
+func (e *StartError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,51 @@
+package goit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ory/dockertest/v3"
+)
+
+type repoContainer struct {
+	o *dockertest.RunOptions
+}
+
+func (c *repoContainer) Options() (*dockertest.RunOptions, error) {
+	return c.o, nil
+}
+
+func (c *repoContainer) AfterStart(context.Context, *dockertest.Resource) error {
+	return nil
+}
+
+func TestDescribe(t *testing.T) {
+	cases := map[string]Container{
+		"app":         &fakeContainer{name: "app"},
+		"db":          &repoContainer{o: &dockertest.RunOptions{Hostname: "db", Repository: "postgres"}},
+		"postgres:13": &repoContainer{o: &dockertest.RunOptions{Repository: "postgres", Tag: "13"}},
+	}
+	for want, c := range cases {
+		if got := describe(c); got != want {
+			t.Errorf("expected %s, found: %s", want, got)
+		}
+	}
+}
+
+func TestAsStartError(t *testing.T) {
+	err := asStartError("", PhaseConnect, errors.New("no docker"))
+
+	var se *StartError
+	if !errors.As(err, &se) || se.Phase != PhaseConnect || se.Container != "" {
+		t.Fatalf("expected an environment start error, found: %v", err)
+	}
+	if err.Error() != "environment failed during connect: no docker" {
+		t.Errorf("unexpected message: %s", err)
+	}
+
+	wrapped := newStartError("db", PhaseWait, errors.New("timeout"))
+	if asStartError("", PhaseDependencies, wrapped) != error(wrapped) {
+		t.Errorf("expected start errors to be kept")
+	}
+}
@@ -0,0 +1,79 @@
+package compose_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/tclemos/goit"
+	"github.com/tclemos/goit/compose"
+)
+
+var project *compose.Project
+
+func TestMain(m *testing.M) {
+
+	// Load the services of the compose file next to this test
+	p, err := compose.Load(compose.Params{
+		AfterStart: map[string]func(context.Context, *compose.Service) error{
+			"db": func(ctx context.Context, s *compose.Service) error {
+				conn, err := pgx.Connect(ctx, dbURL(s))
+				if err != nil {
+					return err
+				}
+				defer conn.Close(ctx)
+
+				_, err = conn.Exec(ctx, "CREATE TABLE IF NOT EXISTS fixtures (name varchar(50))")
+				return err
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	project = p
+
+	// Start the services, run tests and stop containers
+	goit.Main(m, goit.DefaultOptions(), project.Containers()...)
+}
+
+func dbURL(s *compose.Service) string {
+	return fmt.Sprintf("postgres://app:secret@%s/app?sslmode=disable", s.Address(5432))
+}
+
+func TestDatabase(t *testing.T) {
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, dbURL(project.Service("db")))
+	if err != nil {
+		t.Fatalf("Unable to connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	// the table is created by the AfterStart of the service
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM fixtures").Scan(&n); err != nil {
+		t.Errorf("Failed to query fixtures: %v", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	conn, err := net.DialTimeout("tcp", project.Service("cache").Address(6379), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Unable to connect to redis: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprint(conn, "PING\r\n"); err != nil {
+		t.Fatalf("Failed to ping redis: %v", err)
+	}
+	answer, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || strings.TrimSpace(answer) != "+PONG" {
+		t.Errorf("Expected +PONG, found: %q, err: %v", answer, err)
+	}
+}
@@ -0,0 +1,44 @@
+package config_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/tclemos/goit/config"
+)
+
+var env *config.Environment
+
+func TestMain(m *testing.M) {
+
+	// Load goit.yaml, start the containers, run tests and stop containers
+	env = config.MustLoad("goit.yaml")
+	env.Main(m)
+}
+
+func TestMigrations(t *testing.T) {
+
+	ctx := context.Background()
+
+	url := env.Postgres("db").Url()
+
+	conn, err := pgx.Connect(ctx, url.String())
+	if err != nil {
+		t.Fatalf("Unable to connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	// the table is created by the migrations of the goit.yaml
+	if _, err := conn.Exec(ctx, "INSERT INTO orders (item) VALUES ('book')"); err != nil {
+		t.Fatalf("failed to insert order: %v", err)
+	}
+
+	var count int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM orders").Scan(&count); err != nil {
+		t.Fatalf("failed to count orders: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 order, found: %d", count)
+	}
+}
@@ -0,0 +1 @@
+CREATE TABLE orders (id serial PRIMARY KEY, item varchar(50) NOT NULL);
@@ -1,19 +1,15 @@
 package main_test
 
 import (
-	"context"
-	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
+	"strings"
 	"testing"
-	"time"
 
-	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
-	"github.com/sethvargo/go-retry"
 	"github.com/tclemos/goit"
 	"github.com/tclemos/goit/dockerfile"
+	"github.com/tclemos/goit/wait"
 )
 
 const (
@@ -21,16 +17,12 @@ const (
 	port = "8080"
 )
 
-func TestMain(m *testing.M) {
-
-	ctx := context.Background()
+var c *dockerfile.Container
 
-	pb := map[docker.Port][]docker.PortBinding{
-		docker.Port(fmt.Sprintf("%s/tcp", port)): {{HostIP: host, HostPort: port}},
-	}
+func TestMain(m *testing.M) {
 
 	// Prepare container
-	c := dockerfile.NewContainer(dockerfile.Params{
+	c = dockerfile.NewContainer(dockerfile.Params{
 		// specify here the container name
 		ContainerName: "myapp",
 
@@ -47,52 +39,23 @@ func TestMain(m *testing.M) {
 			"MYAPP_PORT": port,
 		},
 
-		// define the port bindings to open external ports to the host
-		PortBindings: pb,
-
-		// use the AfterStart function to make sure your container is ready for test,
-		// for example, make a request to a known URL of your service to make sure
-		// it's up and running with a retry logic, if it fails, return
-		// an error to stop de test pipeline
-		AfterStart: func(c context.Context, r *dockertest.Resource, m *map[string]interface{}) error {
-
-			b, _ := retry.NewFibonacci(500 * time.Millisecond)
-			b = retry.WithMaxRetries(10, b)
-			b = retry.WithCappedDuration(20*time.Second, b)
-
-			err := retry.Do(c, b, func(ctx context.Context) error {
-				addr := fmt.Sprintf("http://localhost:%s/ping", port)
-				res, err := http.DefaultClient.Get(addr)
-				if err != nil || res.StatusCode != http.StatusOK {
-					fmt.Println("waiting on application to initialize...")
-					return retry.RetryableError(err)
-				}
-				return nil
-			})
-			if err != nil {
-				return err
-			}
-
-			return nil
-		},
-	})
-
-	// Start container
-	goit.Start(ctx, c)
+		// the exposed ports are mapped to free host ports chosen by docker, use
+		// the container Endpoint or URL to find them, set PortBindings only if
+		// you need fixed host ports
 
-	// Run tests
-	code := m.Run()
-
-	// Stop containers
-	goit.Stop()
+		// use the WaitFor strategy to make sure your container is ready for test,
+		// for example, make a request to a known URL of your service until it
+		// answers, if it times out, the test pipeline is stopped
+		WaitFor: wait.ForHTTP(port, "/ping").WithBody("pong"),
+	})
 
-	// finalize test execution
-	os.Exit(code)
+	// Start container, run tests and stop containers
+	goit.Main(m, goit.DefaultOptions(), c)
 }
 
 func TestFoo(t *testing.T) {
 
-	addr := fmt.Sprintf("http://localhost:%s/foo", port)
+	addr := c.URL("http", port) + "/foo"
 	res, err := http.DefaultClient.Get(addr)
 	if err != nil || res.StatusCode != http.StatusOK {
 		t.Error("Failed to get foo API")
@@ -108,3 +71,14 @@ func TestFoo(t *testing.T) {
 		t.Errorf("Invalid response body for foo API, expected: bar, found: %s", bodyText)
 	}
 }
+
+func TestLogs(t *testing.T) {
+
+	// dump the app output into the test output if this test fails
+	goit.DumpLogsOnFailure(t, c)
+
+	// the output of the container is captured, timestamps prefix each line
+	if logs := c.Logs(); !strings.Contains(logs, "Server address: "+host+":"+port) {
+		t.Errorf("Expected the server address to be logged, found:\n%s", logs)
+	}
+}
@@ -1,9 +1,7 @@
 package kafka_test
 
 import (
-	"context"
 	"encoding/json"
-	"os"
 	"testing"
 
 	"github.com/tclemos/goit"
@@ -20,29 +18,23 @@ var c *kafka.Container
 
 func TestMain(m *testing.M) {
 
-	ctx := context.Background()
-
 	// Prepare container
 	c = kafka.NewContainer(kafka.Params{
+		ContainerParams: goit.ContainerParams{
+			// kafka gets more memory than the default limit below
+			Resources: goit.Resources{Memory: 1 << 30},
+		},
 		Topics: []string{
 			"TopicOne",
 			"TopicTwo",
 		},
 	})
 
-	// Start container
+	// Start container, run tests and stop containers
 	opt := goit.DefaultOptions()
 	opt.AutoRemoveContainers = true
-	goit.StartWithOptions(ctx, opt, c)
-
-	// Run tests
-	code := m.Run()
-
-	// Stop containers
-	goit.Stop()
-
-	// finalize test execution
-	os.Exit(code)
+	opt.Resources = goit.Resources{Memory: 512 << 20, CPUs: 1}
+	goit.Main(m, opt, c)
 }
 
 func TestKafka(t *testing.T) {
@@ -2,46 +2,44 @@ package postgres_test
 
 import (
 	"context"
-	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v4"
+	"github.com/ory/dockertest/v3"
 	"github.com/tclemos/goit"
 	"github.com/tclemos/goit/postgres"
 )
 
 const (
-	Port     = 5432
 	User     = "postgres_user"
 	Password = "postgres_password"
 	Database = "postgres_database"
+
+	InitScript = "CREATE TABLE fixtures (name varchar(50)); INSERT INTO fixtures VALUES ('seeded');"
 )
 
 var c *postgres.Container
 
 func TestMain(m *testing.M) {
 
-	ctx := context.Background()
-
 	// Prepare container
 	c = postgres.NewContainer(postgres.Params{
-		Port:     Port,
+		ContainerParams: goit.ContainerParams{
+			// scripts in this directory are executed when the database is created
+			Files: []goit.File{{
+				Source: goit.FromBytes([]byte(InitScript), 0644),
+				Target: "/docker-entrypoint-initdb.d/init.sql",
+			}},
+		},
 		User:     User,
 		Password: Password,
 		Database: Database,
 	})
 
-	// Start container
-	goit.Start(ctx, c)
-
-	// Run tests
-	code := m.Run()
-
-	// Stop containers
-	goit.Stop()
-
-	// finalize test execution
-	os.Exit(code)
+	// Start container, run tests and stop containers
+	goit.Main(m, goit.DefaultOptions(), c)
 }
 
 func TestPostgres(t *testing.T) {
@@ -129,3 +127,207 @@ func TestPostgres(t *testing.T) {
 		return
 	}
 }
+
+func TestPostgresExec(t *testing.T) {
+
+	ctx := context.Background()
+
+	// run psql inside the container
+	res, err := goit.Exec(ctx, c, []string{"psql", "-U", User, "-d", Database, "-tAc", "SELECT current_user;"}, goit.ExecOptions{
+		Env: []string{"PGPASSWORD=" + Password},
+	})
+	if err != nil {
+		t.Errorf("Unable to exec psql: %v", err)
+		return
+	}
+
+	if res.ExitCode != 0 {
+		t.Errorf("psql exited with %d: %s", res.ExitCode, res.Stderr)
+		return
+	}
+
+	if user := strings.TrimSpace(res.Stdout); user != User {
+		t.Errorf("Invalid user, expected %s, found: %s", User, user)
+	}
+}
+
+func TestPostgresFiles(t *testing.T) {
+
+	ctx := context.Background()
+
+	// the init script was copied before postgres started
+	b, err := goit.ReadFile(ctx, c, "/docker-entrypoint-initdb.d/init.sql")
+	if err != nil {
+		t.Errorf("Unable to read init script: %v", err)
+		return
+	}
+	if string(b) != InitScript {
+		t.Errorf("Invalid init script, expected %s, found: %s", InitScript, b)
+	}
+
+	url := c.Url()
+	conn, err := pgx.Connect(ctx, url.String())
+	if err != nil {
+		t.Errorf("Unable to connect to database: %v", err)
+		return
+	}
+	defer conn.Close(ctx)
+
+	var name string
+	if err := conn.QueryRow(ctx, "SELECT name FROM fixtures;").Scan(&name); err != nil {
+		t.Errorf("Unable to select fixtures: %v", err)
+		return
+	}
+	if name != "seeded" {
+		t.Errorf("Invalid fixture, expected seeded, found: %s", name)
+	}
+}
+
+func TestPostgresPerTest(t *testing.T) {
+
+	for _, name := range []string{"first", "second"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			// each subtest gets its own database, stopped when the subtest
+			// finishes, its data is kept in memory to speed it up
+			db := postgres.NewContainer(postgres.Params{
+				ContainerParams: goit.ContainerParams{
+					Mounts: []goit.Mount{goit.TmpfsMount("/var/lib/postgresql/data", 256<<20)},
+				},
+				User:     User,
+				Password: Password,
+				Database: name,
+			})
+			goit.StartT(t, db)
+
+			url := db.Url()
+			conn, err := pgx.Connect(ctx, url.String())
+			if err != nil {
+				t.Errorf("Unable to connect to database: %v", err)
+				return
+			}
+			defer conn.Close(ctx)
+
+			var current string
+			if err := conn.QueryRow(ctx, "SELECT current_database();").Scan(&current); err != nil {
+				t.Errorf("Unable to select current database: %v", err)
+				return
+			}
+
+			if current != name {
+				t.Errorf("Invalid database, expected %s, found: %s", name, current)
+			}
+		})
+	}
+}
+
+func TestPostgresSnapshot(t *testing.T) {
+
+	ctx := context.Background()
+	opt := goit.DefaultOptions()
+	opt.RestoreSnapshots = true
+
+	newContainer := func() *postgres.Container {
+		return postgres.NewContainer(postgres.Params{
+			ContainerParams: goit.ContainerParams{
+				// change the key when the seed changes
+				SnapshotKey: "seed-v1",
+			},
+			User:     User,
+			Password: Password,
+			Database: "snapshot",
+		})
+	}
+
+	connect := func(t *testing.T, db *postgres.Container) *pgx.Conn {
+		url := db.Url()
+		conn, err := pgx.Connect(ctx, url.String())
+		if err != nil {
+			t.Fatalf("Unable to connect to database: %v", err)
+		}
+		t.Cleanup(func() { conn.Close(ctx) })
+		return conn
+	}
+
+	var img string
+	t.Run("seed", func(t *testing.T) {
+		db := newContainer()
+		goit.StartTWithOptions(t, opt, db)
+
+		// the seed is skipped when the snapshot of a previous run is restored
+		if !goit.Restored(db) {
+			conn := connect(t, db)
+			if _, err := conn.Exec(ctx, "CREATE TABLE seeds AS SELECT 'seeded'::text AS name;"); err != nil {
+				t.Fatalf("Unable to seed database: %v", err)
+			}
+			conn.Close(ctx)
+		}
+
+		var err error
+		if img, err = goit.Snapshot(ctx, db); err != nil {
+			t.Fatalf("Unable to snapshot database: %v", err)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		db := newContainer()
+
+		// the snapshot is removed after the container
+		var pool *dockertest.Pool
+		t.Cleanup(func() {
+			if pool != nil {
+				_ = pool.Client.RemoveImage(img)
+			}
+		})
+		goit.StartTWithOptions(t, opt, db)
+		pool = goit.HandleOf(db).Pool()
+
+		if !goit.Restored(db) {
+			t.Fatalf("Expected the database to be restored from %s", img)
+		}
+
+		var name string
+		if err := connect(t, db).QueryRow(ctx, "SELECT name FROM seeds;").Scan(&name); err != nil {
+			t.Fatalf("Unable to select seeds: %v", err)
+		}
+		if name != "seeded" {
+			t.Errorf("Invalid seed, expected seeded, found: %s", name)
+		}
+	})
+}
+
+func TestPostgresRestart(t *testing.T) {
+
+	ctx := context.Background()
+
+	db := postgres.NewContainer(postgres.Params{
+		User:     User,
+		Password: Password,
+		Database: "restart",
+	})
+	goit.StartT(t, db)
+
+	url := db.Url()
+
+	// postgres comes back at the same url after it is restarted
+	if err := db.Restart(ctx, 10*time.Second); err != nil {
+		t.Fatalf("Unable to restart database: %v", err)
+	}
+	if err := goit.WaitUntilReady(ctx, db); err != nil {
+		t.Fatalf("Database is not ready after restart: %v", err)
+	}
+
+	conn, err := pgx.Connect(ctx, url.String())
+	if err != nil {
+		t.Fatalf("Unable to connect to restarted database: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if err := conn.Ping(ctx); err != nil {
+		t.Errorf("Unable to ping restarted database: %v", err)
+	}
+}
@@ -1,9 +1,7 @@
 package sqs_test
 
 import (
-	"context"
 	"encoding/json"
-	"os"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -13,7 +11,6 @@ import (
 
 const (
 	Region    = "eu-central-1"
-	Port      = 4566
 	QueueName = "example_queue"
 )
 
@@ -21,28 +18,16 @@ var c *aws.Container
 
 func TestMain(m *testing.M) {
 
-	ctx := context.Background()
-
 	// Prepare container
 	c = aws.NewContainer(aws.Params{
 		Region: Region,
-		Port:   Port,
 		SqsQueues: []aws.SqsQueue{
 			{Name: QueueName},
 		},
 	})
 
-	// Start container
-	goit.Start(ctx, c)
-
-	// Run tests
-	code := m.Run()
-
-	// Stop containers
-	goit.Stop()
-
-	// finalize test execution
-	os.Exit(code)
+	// Start container, run tests and stop containers
+	goit.Main(m, goit.DefaultOptions(), c)
 }
 
 func TestSqs(t *testing.T) {
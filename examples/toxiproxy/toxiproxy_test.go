@@ -0,0 +1,84 @@
+package toxiproxy_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/tclemos/goit"
+	"github.com/tclemos/goit/postgres"
+	"github.com/tclemos/goit/toxiproxy"
+)
+
+var (
+	db    *postgres.Container
+	proxy *toxiproxy.Container
+)
+
+func TestMain(m *testing.M) {
+
+	// Prepare containers, postgres is reached through toxiproxy
+	db = postgres.NewContainer(postgres.Params{
+		User:     "postgres",
+		Password: "postgres",
+		Database: "toxiproxy",
+	})
+	proxy = toxiproxy.NewContainer(toxiproxy.Params{
+		Targets: []toxiproxy.Target{
+			{Container: db, Port: "5432/tcp"},
+		},
+	})
+
+	// Start containers, run tests and stop containers
+	goit.Main(m, goit.DefaultOptions(), db, proxy)
+}
+
+func TestLatency(t *testing.T) {
+
+	ctx := context.Background()
+	p := proxy.Proxy(db, "5432/tcp")
+
+	// the url of the database points to toxiproxy
+	url := db.Url()
+	conn, err := pgx.Connect(ctx, url.String())
+	if err != nil {
+		t.Fatalf("Unable to connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	name, err := p.AddToxic(ctx, toxiproxy.Latency(500*time.Millisecond, 0))
+	if err != nil {
+		t.Fatalf("Unable to add latency: %v", err)
+	}
+	defer p.RemoveToxic(ctx, name)
+
+	start := time.Now()
+	if err := conn.Ping(ctx); err != nil {
+		t.Fatalf("Unable to ping database: %v", err)
+	}
+
+	if d := time.Since(start); d < 500*time.Millisecond {
+		t.Errorf("Expected the ping to take at least 500ms, took: %v", d)
+	}
+}
+
+func TestPartition(t *testing.T) {
+
+	ctx := context.Background()
+	p := proxy.Proxy(db, "5432/tcp")
+
+	if err := p.Disable(ctx); err != nil {
+		t.Fatalf("Unable to disable proxy: %v", err)
+	}
+	defer p.Enable(ctx)
+
+	cctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	url := db.Url()
+	if conn, err := pgx.Connect(cctx, url.String()); err == nil {
+		conn.Close(ctx)
+		t.Errorf("Expected the connection to fail while the proxy is disabled")
+	}
+}
@@ -0,0 +1,98 @@
+package goit
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+)
+
+// ExecOptions configures a command executed inside a container
+type ExecOptions struct {
+	// Env is added to the container environment, e.g. PGPASSWORD=secret
+	Env []string
+
+	// WorkingDir is the directory the command runs in, it requires sh in
+	// the container image
+	WorkingDir string
+
+	// User runs the command as the user, e.g. postgres or 1000:1000
+	User string
+
+	// Stdin is sent to the command standard input
+	Stdin io.Reader
+}
+
+// ExecResult is the outcome of a command executed inside a container
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Exec runs the command inside a container started by goit, e.g.
+//
+//	res, err := goit.Exec(ctx, db, []string{"psql", "-U", "postgres", "-c", "SELECT 1"}, goit.ExecOptions{})
+//
+// A non zero exit code is returned in the result, the error is only set
+// when the command can't be executed
+func Exec(ctx context.Context, c Container, cmd []string, opt ExecOptions) (ExecResult, error) {
+	h := HandleOf(c)
+	if h == nil {
+		return ExecResult{}, errors.Errorf("container is not started: %s", describe(c))
+	}
+	return h.Exec(ctx, cmd, opt)
+}
+
+// Exec runs the command inside the container, see Exec
+func (h *Handle) Exec(ctx context.Context, cmd []string, opt ExecOptions) (ExecResult, error) {
+	p, r := h.Pool(), h.Resource()
+	if p == nil || r == nil {
+		return ExecResult{}, errors.New("container is not started")
+	}
+	if len(cmd) == 0 {
+		return ExecResult{}, errors.New("command is empty")
+	}
+
+	if opt.WorkingDir != "" {
+		cmd = append([]string{"sh", "-c", `cd "$0" && exec "$@"`, opt.WorkingDir}, cmd...)
+	}
+
+	exec, err := p.Client.CreateExec(docker.CreateExecOptions{
+		Context:      ctx,
+		Container:    r.Container.ID,
+		Cmd:          cmd,
+		Env:          opt.Env,
+		User:         opt.User,
+		AttachStdin:  opt.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return ExecResult{}, errors.Wrapf(err, "failed to create exec: %v", cmd)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = p.Client.StartExec(exec.ID, docker.StartExecOptions{
+		Context:      ctx,
+		InputStream:  opt.Stdin,
+		OutputStream: &stdout,
+		ErrorStream:  &stderr,
+	})
+	if err != nil {
+		return ExecResult{}, errors.Wrapf(err, "failed to start exec: %v", cmd)
+	}
+
+	ins, err := p.Client.InspectExec(exec.ID)
+	if err != nil {
+		return ExecResult{}, errors.Wrapf(err, "failed to inspect exec: %v", cmd)
+	}
+
+	return ExecResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: ins.ExitCode,
+	}, nil
+}
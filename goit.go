@@ -1,26 +1,33 @@
 package goit
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"fmt"
 	"path/filepath"
-	"testing"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/ahmetb/dlog"
+	"github.com/google/uuid"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
 	"github.com/tclemos/goit/log"
 )
 
 var (
-	pool      *dockertest.Pool
-	resources []*dockertest.Resource
+	defaultEnvMu sync.Mutex
+	defaultEnv   *Environment
 )
 
 type Options struct {
-	// AutoRemoveContainers set the containers to remove itself when finished, e.g. docker run --rm
+	// AutoRemoveContainers is ignored, the containers are removed by Stop,
+	// or by the reaper when the tests are killed.
+	//
+	// Deprecated: docker auto remove would also remove the containers
+	// stopped by Handle.Stop or Handle.Kill, so it is never set.
 	AutoRemoveContainers bool
 
 	// RestartContainers define if a container must restart after it is finished
@@ -28,69 +35,108 @@ type Options struct {
 
 	// ExpireContainersAfterSeconds sets a container to be destroid after an amount of seconds
 	ExpireContainersAfterSeconds uint
+
+	// Reuse keeps the containers running after Stop and attaches to them
+	// in the next runs, as long as they are created with the same options,
+	// AfterStart is executed again on reused containers so it must be idempotent
+	Reuse bool
+
+	// Recreate removes the reusable containers and creates new ones, it
+	// can also be enabled with the GOIT_RECREATE environment variable
+	Recreate bool
+
+	// RestoreSnapshots starts the containers from their snapshot images,
+	// when they exist, instead of pulling or building them, see Snapshot
+	RestoreSnapshots bool
+
+	// PullPolicy tells when the images are pulled, the containers can
+	// override it, defaults to PullIfNotPresent
+	PullPolicy PullPolicy
+
+	// Offline never pulls images, the start fails when an image isn't
+	// available locally, it can also be enabled with the GOIT_OFFLINE
+	// environment variable
+	Offline bool
+
+	// DisableReaper disables the sidecar container that removes the resources
+	// of test processes killed before Stop, it can also be disabled with the
+	// GOIT_REAPER_DISABLED environment variable
+	DisableReaper bool
+
+	// ReaperImage replaces DefaultReaperImage
+	ReaperImage string
+
+	// LogsDir is the directory where the output of each container is
+	// written, instead of the goit log, it can also be set with the
+	// GOIT_LOGS_DIR environment variable
+	LogsDir string
+
+	// DisableSignalHandler keeps goit from stopping the containers when the
+	// process receives SIGINT or SIGTERM, e.g. when go test is interrupted
+	DisableSignalHandler bool
+
+	// Logger receives the goit messages and the containers output, at
+	// debug level, defaults to stdout filtered by GOIT_LOG_LEVEL, use
+	// log.Silent to drop them
+	Logger log.Logger
+
+	// Resources are the default resource limits of the containers, the
+	// containers override them with their own limits
+	Resources Resources
+
+	// MaxParallelism limits how many containers are started at the same time,
+	// zero means no limit and one starts the containers one after another
+	MaxParallelism int
+
+	// Up names an environment whose containers outlive the process, as goit
+	// up does: they are reused, labeled with the name so the test runs
+	// attach to them, and the reaper is disabled
+	Up string
+
+	// Attach names the goit up environment whose containers are used
+	// instead of starting new ones, when they are created with the same
+	// options, the GOIT_ATTACH environment variable replaces it and
+	// GOIT_ATTACH=false disables attaching
+	Attach string
 }
 
+// Start the integration test environment with the default options,
+// panics if any container fails to start
 func Start(ctx context.Context, containers ...Container) {
 	StartWithOptions(ctx, DefaultOptions(), containers...)
 }
 
-// Start the integration test environment
+// Start the integration test environment, panics if any container fails to start
 func StartWithOptions(ctx context.Context, opt Options, containers ...Container) {
-	log.Log("initializing containers")
-	resources = []*dockertest.Resource{}
-
-	var err error
-	pool, err = dockertest.NewPool("")
-	if err != nil {
-		log.Errorf(err, "failed to create docker pool")
-		panic(err)
-	}
-
-	for _, c := range containers {
-		var r *dockertest.Resource
-		switch cf := c.(type) {
-		case containerFromDockerFile:
-			r, err = startContainerFromDockerFile(ctx, pool, cf, opt)
-			handleContainerErr(err, "can't start container")
-		case containerFromRepository:
-			r, err = startContainerFromRepository(ctx, pool, cf, opt)
-			handleContainerErr(err, "can't start container")
-		default:
-			panic("unknown container type")
-		}
-
-		log.Logf("executing AfterStart for container: %s", r.Container.Name)
-		err = c.AfterStart(ctx, r)
-		handleContainerErr(err, fmt.Sprintf("failed to execute AfterStarted for container: %s", r.Container.Name))
+	getDefaultEnv(opt).start(ctx, opt, containers...)
+}
 
-		resources = append(resources, r)
-	}
+// StartE starts the integration test environment, if any container fails
+// to start, the containers already started are purged, except the reused
+// ones, and a *StartError is returned
+func StartE(ctx context.Context, opt Options, containers ...Container) error {
+	return getDefaultEnv(opt).startE(ctx, opt, containers...)
 }
 
 // Stop the integration test environment
 func Stop() {
-	for _, r := range resources {
-		log.Logf("purging container: %s", r.Container.Name)
-		err := pool.Purge(r)
-		if err != nil {
-			log.Errorf(err, "could not purge container: %v", r.Container.Name)
-		} else {
-			log.Logf("container purged: %s", r.Container.Name)
-		}
+	defaultEnvMu.Lock()
+	env := defaultEnv
+	defaultEnvMu.Unlock()
+
+	if env != nil {
+		env.Stop()
 	}
 }
 
-func Run(m *testing.M) int {
-	defer func() {
-		err := recover()
-		if recover() != nil {
-			Stop()
-		}
-		if err != nil {
-			panic(fmt.Sprintf("rethrowing panic after Stoping containers, err: %v", err))
-		}
-	}()
-	return m.Run()
+func getDefaultEnv(opt Options) *Environment {
+	defaultEnvMu.Lock()
+	defer defaultEnvMu.Unlock()
+
+	if defaultEnv == nil {
+		defaultEnv = NewEnvironment(opt)
+	}
+	return defaultEnv
 }
 
 func DefaultOptions() Options {
@@ -101,112 +147,392 @@ func DefaultOptions() Options {
 	}
 }
 
-// startContainer creates and initializes a container accordingly to the provided options
-func startContainerFromDockerFile(ctx context.Context, p *dockertest.Pool, c containerFromDockerFile, opt Options) (*dockertest.Resource, error) {
-	log.Logf("starting new container")
+// startConfig holds the settings shared by the containers of an environment
+type startConfig struct {
+	opt     Options
+	network *dockertest.Network
+
+	// attach names the goit up environment whose containers are reused,
+	// the other containers are started as usual
+	attach string
+
+	// logCtx is canceled when the environment stops, ending the log
+	// redirects tracked by logs
+	logCtx context.Context
+	logs   *sync.WaitGroup
+
+	// volumes tracks the volumes created for the environment
+	volumes *volumeSet
+}
+
+// createOptions holds the container settings that dockertest RunOptions
+// can't express
+type createOptions struct {
+	files       []File
+	mounts      []Mount
+	snapshotKey string
+	resources   Resources
+	aliases     []string
+}
+
+// createOptionsOf returns the create options declared by the container,
+// with the environment defaults
+func createOptionsOf(c Container, opt Options) createOptions {
+	return createOptions{
+		files:       filesOf(c),
+		mounts:      mountsOf(c),
+		snapshotKey: snapshotKeyOf(c),
+		resources:   resourcesOf(c).merge(opt.Resources),
+		aliases:     aliasesOf(c),
+	}
+}
+
+// startContainerFromDockerFile builds the image and initializes a container accordingly to the provided options
+func startContainerFromDockerFile(ctx context.Context, p *dockertest.Pool, c containerFromDockerFile, cfg startConfig) (*dockertest.Resource, error) {
+	l := log.FromContext(ctx)
+	n := c.ContainerName()
+	host := n
+	if ch, ok := c.(containerWithHostname); ok && ch.Hostname() != "" {
+		host = ch.Hostname()
+	}
 	dir, file := filepath.Split(c.DockerFilePath())
-	r, err := p.BuildAndRunWithBuildOptions(&dockertest.BuildOptions{
+	b := &dockertest.BuildOptions{
 		ContextDir: dir,
 		Dockerfile: file,
 		BuildArgs:  c.BuildArgs(),
-	}, &dockertest.RunOptions{
-		Name:         c.ContainerName(),
+	}
+	o := &dockertest.RunOptions{
+		Name:         n,
+		Hostname:     host,
+		Repository:   n,
 		Env:          c.Env(),
 		PortBindings: c.PortBindings(),
-	}, getHostConfig(opt))
+	}
+
+	co := createOptionsOf(c, cfg.opt)
+	snapRepo, snapTag, err := snapshotImage(n, b, o, co)
 	if err != nil {
-		log.Error(err, "failed to start container, check if docker is running and exposing deamon on tcp://localhost:2375")
-		return nil, err
+		return nil, newStartError(n, PhaseOptions, err)
 	}
 
-	err = r.Expire(opt.ExpireContainersAfterSeconds)
+	r, err := reuseContainer(ctx, p, b, o, co, cfg)
 	if err != nil {
-		log.Errorf(err, "could not setup container to expire: %s", r.Container.Name)
-		return nil, err
+		return nil, newStartError(n, PhaseRun, err)
+	}
+	if r != nil {
+		if err := attachContainer(ctx, p, c, n, r, cfg, host); err != nil {
+			return r, err
+		}
+		HandleOf(c).setSnapshot(snapRepo, snapTag, false)
+		return r, nil
 	}
 
-	log.Logf("container started: %s", r.Container.Name)
-	return r, nil
-}
+	restored := restoreSnapshot(ctx, p, o, snapRepo, snapTag, cfg)
+	if !restored {
+		l.Info("building image", "container", n, "phase", PhaseBuild)
+		var out bytes.Buffer
+		err = p.Client.BuildImage(docker.BuildImageOptions{
+			Context:      ctx,
+			Name:         n,
+			Dockerfile:   b.Dockerfile,
+			ContextDir:   b.ContextDir,
+			BuildArgs:    b.BuildArgs,
+			Labels:       resourceLabels(cfg.opt),
+			Pull:         pullPolicyOf(c, cfg.opt) == PullAlways,
+			AuthConfigs:  buildAuth(ctx),
+			OutputStream: &out,
+		})
+		if err != nil {
+			l.Error("failed to build image", "container", n, "phase", PhaseBuild, "err", err, "output", out.String())
+			return nil, newStartError(n, PhaseBuild, err)
+		}
+	}
 
-// startContainerFromRepository creates and initializes a container accordingly to the provided options
-func startContainerFromRepository(ctx context.Context, p *dockertest.Pool, c containerFromRepository, opt Options) (*dockertest.Resource, error) {
+	if host != n {
+		// named after the hash, reused containers keep their names
+		o.Name = fmt.Sprintf("%s-%s", n, uuid.New().String()[:8])
+	}
 
-	log.Logf("starting new container")
+	r, err = runContainer(ctx, p, n, o, co, cfg)
+	if err != nil {
+		return r, err
+	}
+
+	h := bindHandle(c, p, r, host)
+	h.setSnapshot(snapRepo, snapTag, restored)
+	captureLogs(ctx, p, h, 0, cfg)
 
+	return r, nil
+}
+
+// startContainerFromRepository pulls the image and initializes a container accordingly to the provided options
+func startContainerFromRepository(ctx context.Context, p *dockertest.Pool, c containerFromRepository, cfg startConfig) (*dockertest.Resource, error) {
+	l := log.FromContext(ctx)
+	n := describe(c)
 	o, err := c.Options()
-	log.Logf("loading container with options: %v", o)
-	handleContainerErr(err, "can't load container")
+	if err != nil {
+		l.Error("can't load container", "container", n, "phase", PhaseOptions, "err", err)
+		return nil, newStartError(n, PhaseOptions, err)
+	}
+	l.Debug("loading container", "container", n, "options", fmt.Sprintf("%+v", *o))
 
-	r, err := p.RunWithOptions(o, getHostConfig(opt))
+	co := createOptionsOf(c, cfg.opt)
+	snapRepo, snapTag, err := snapshotImage(o.Hostname, nil, o, co)
 	if err != nil {
-		log.Error(err, "failed to start container, check if docker is running and exposing deamon on tcp://localhost:2375")
-		return nil, err
+		return nil, newStartError(n, PhaseOptions, err)
+	}
+
+	r, err := reuseContainer(ctx, p, nil, o, co, cfg)
+	if err != nil {
+		return nil, newStartError(n, PhaseRun, err)
+	}
+	if r != nil {
+		if err := attachContainer(ctx, p, c, n, r, cfg, containerAlias(o, r)); err != nil {
+			return r, err
+		}
+		HandleOf(c).setSnapshot(snapRepo, snapTag, false)
+		return r, nil
 	}
 
-	redirectLogs(ctx, p, r)
+	restored := restoreSnapshot(ctx, p, o, snapRepo, snapTag, cfg)
+	if !restored {
+		if err := pullImage(ctx, p, o, pullPolicyOf(c, cfg.opt)); err != nil {
+			l.Error("failed to pull image", "container", n, "image", imageName(o), "phase", PhasePull, "err", err)
+			return nil, newStartError(n, PhasePull, err)
+		}
+	}
 
-	err = r.Expire(opt.ExpireContainersAfterSeconds)
+	r, err = runContainer(ctx, p, n, o, co, cfg)
 	if err != nil {
-		log.Errorf(err, "could not setup container to expire: %s", r.Container.Name)
-		return nil, err
+		return r, err
 	}
 
-	log.Logf("container started: %s", r.Container.Name)
+	h := bindHandle(c, p, r, containerAlias(o, r))
+	h.setSnapshot(snapRepo, snapTag, restored)
+	captureLogs(ctx, p, h, 0, cfg)
+
 	return r, nil
 }
 
-func getHostConfig(opt Options) func(*docker.HostConfig) {
-	var restartPolicyName string
-	if opt.RestartContainers {
-		restartPolicyName = "yes"
-	} else {
-		restartPolicyName = "no"
+// reuseContainer returns a running container created with the same options
+// when the environment reuses containers or attaches to goit up, otherwise
+// it labels the options with their hash, so the next runs can find the new
+// container
+func reuseContainer(ctx context.Context, p *dockertest.Pool, b *dockertest.BuildOptions, o *dockertest.RunOptions, co createOptions, cfg startConfig) (*dockertest.Resource, error) {
+	if !cfg.opt.Reuse && cfg.attach == "" {
+		return nil, nil
 	}
 
-	return func(config *docker.HostConfig) {
-		config.AutoRemove = opt.AutoRemoveContainers
-		config.RestartPolicy = docker.RestartPolicy{Name: restartPolicyName}
+	h, err := reuseHash(b, o, co)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.opt.Reuse {
+		return findAttachable(p, cfg.attach, h, o.Name)
 	}
+
+	r, err := findReusable(ctx, p, h, shouldRecreate(cfg.opt))
+	if err != nil || r != nil {
+		return r, err
+	}
+
+	o.Labels = withLabel(o.Labels, labelReuseHash, h)
+	for k, v := range upLabels(cfg.opt) {
+		o.Labels = withLabel(o.Labels, k, v)
+	}
+	return nil, nil
 }
 
-func redirectLogs(ctx context.Context, p *dockertest.Pool, r *dockertest.Resource) {
+// attachContainer makes a reused container part of the environment
+func attachContainer(ctx context.Context, p *dockertest.Pool, c Container, n string, r *dockertest.Resource, cfg startConfig, alias string) error {
+	l := log.FromContext(ctx)
+	l.Info("reusing container", "container", n, "id", r.Container.ID)
+	if err := attachReused(p, r, cfg, append([]string{alias}, aliasesOf(c)...)); err != nil {
+		l.Error("failed to attach reused container", "container", n, "phase", PhaseNetwork, "err", err)
+		return newStartError(n, PhaseNetwork, err)
+	}
 
-	var b bytes.Buffer
-	reader := dlog.NewReader(&b)
-	scanner := bufio.NewScanner(reader)
+	h := bindHandle(c, p, r, alias)
+	captureLogs(ctx, p, h, time.Now().Unix(), cfg)
 
-	err := pool.Client.Logs(docker.LogsOptions{
-		Context: ctx,
+	return nil
+}
 
-		Stderr: true,
-		Stdout: true,
+// runContainer creates, copies the files, starts and sets the container to expire
+func runContainer(ctx context.Context, p *dockertest.Pool, n string, o *dockertest.RunOptions, co createOptions, cfg startConfig) (*dockertest.Resource, error) {
+	l := log.FromContext(ctx)
+	start := time.Now()
+	l.Debug("starting new container", "container", n, "phase", PhaseRun)
+	r, err := createAndStartContainer(ctx, p, o, co, cfg)
+	if err != nil {
+		l.Error("failed to start container, check if docker is running", "container", n, "phase", PhaseRun, "endpoint", p.Client.Endpoint(), "err", err)
+		return nil, newStartError(n, PhaseRun, err)
+	}
 
-		Follow:      true,
-		Timestamps:  true,
-		RawTerminal: true,
+	if cfg.opt.Reuse {
+		l.Info("container started to be reused", "container", r.Container.Name, "duration", time.Since(start))
+		return r, nil
+	}
 
-		Container: r.Container.ID,
+	err = r.Expire(cfg.opt.ExpireContainersAfterSeconds)
+	if err != nil {
+		l.Error("could not setup container to expire", "container", r.Container.Name, "phase", PhaseExpire, "err", err)
+		return r, newStartError(n, PhaseExpire, err)
+	}
 
-		OutputStream: &b,
-		ErrorStream:  &b,
-	})
+	l.Info("container started", "container", r.Container.Name, "duration", time.Since(start))
+	return r, nil
+}
+
+// createAndStartContainer works like dockertest RunWithOptions, but
+// mounts the storage, attaches the container to the environment network
+// and copies the files before it starts, so its alias can be resolved as soon as the other
+// containers boot
+func createAndStartContainer(ctx context.Context, p *dockertest.Pool, o *dockertest.RunOptions, co createOptions, cfg startConfig) (*dockertest.Resource, error) {
+	var exp map[docker.Port]struct{}
+	if len(o.ExposedPorts) > 0 {
+		exp = map[docker.Port]struct{}{}
+		for _, port := range o.ExposedPorts {
+			exp[docker.Port(port)] = struct{}{}
+		}
+	}
+
+	hc := docker.HostConfig{
+		PublishAllPorts: true,
+		Binds:           o.Mounts,
+		Links:           o.Links,
+		PortBindings:    o.PortBindings,
+		ExtraHosts:      o.ExtraHosts,
+		CapAdd:          o.CapAdd,
+		SecurityOpt:     o.SecurityOpt,
+		Privileged:      o.Privileged,
+		DNS:             o.DNS,
+	}
+	getHostConfig(cfg.opt)(&hc)
+	co.resources.apply(&hc)
+
+	labels := o.Labels
+	reused := labels[labelReuseHash] != ""
+	if !reused {
+		for k, v := range resourceLabels(cfg.opt) {
+			labels = withLabel(labels, k, v)
+		}
+	}
 
+	mounts, err := hostMounts(ctx, p, co.mounts, labels, !reused, cfg)
 	if err != nil {
-		log.Error(err, "failed to attach log for container %s", r.Container.Name)
+		return nil, err
+	}
+	hc.Mounts = mounts
+
+	nc := docker.NetworkingConfig{
+		EndpointsConfig: map[string]*docker.EndpointConfig{},
+	}
+	if cfg.network != nil {
+		var aliases []string
+		for _, a := range append([]string{o.Hostname, o.Name}, co.aliases...) {
+			if a != "" {
+				aliases = append(aliases, a)
+			}
+		}
+		nc.EndpointsConfig[cfg.network.Network.Name] = &docker.EndpointConfig{
+			Aliases: aliases,
+		}
+	}
+
+	c, err := p.Client.CreateContainer(docker.CreateContainerOptions{
+		Context: ctx,
+		Name:    o.Name,
+		Config: &docker.Config{
+			Hostname:     o.Hostname,
+			Image:        imageName(o),
+			Env:          o.Env,
+			Entrypoint:   o.Entrypoint,
+			Cmd:          o.Cmd,
+			ExposedPorts: exp,
+			WorkingDir:   o.WorkingDir,
+			Labels:       labels,
+			StopSignal:   "SIGWINCH", // to support Expire timeouts, as dockertest does
+		},
+		HostConfig:       &hc,
+		NetworkingConfig: &nc,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	go func(s *bufio.Scanner, n string) {
-		for s.Scan() {
-			log.Logf("[%s]: %s", n, s.Text())
+	if len(co.files) > 0 {
+		if err := copyTo(ctx, p, c.ID, co.files...); err != nil {
+			_ = p.Client.RemoveContainer(docker.RemoveContainerOptions{ID: c.ID, Force: true, RemoveVolumes: true})
+			return nil, err
 		}
-	}(scanner, r.Container.Name)
+	}
+
+	if err := p.Client.StartContainerWithContext(c.ID, nil, ctx); err != nil {
+		_ = p.Client.RemoveContainer(docker.RemoveContainerOptions{ID: c.ID, Force: true, RemoveVolumes: true})
+		return nil, err
+	}
+
+	return getResource(p, c.ID)
 }
 
-func handleContainerErr(err error, m string, args ...interface{}) {
+// getResource loads a container as a dockertest resource bound to the pool
+func getResource(p *dockertest.Pool, id string) (*dockertest.Resource, error) {
+	c, err := p.Client.InspectContainer(id)
 	if err != nil {
-		log.Errorf(err, m, args...)
-		Stop()
-		panic(err)
+		return nil, err
+	}
+
+	n := strings.TrimPrefix(c.Name, "/")
+	r, ok := p.ContainerByName(fmt.Sprintf("^/?%s$", regexp.QuoteMeta(n)))
+	if !ok {
+		return nil, errors.Errorf("container not found: %s", n)
+	}
+	return r, nil
+}
+
+// containerAlias returns the name used to reach the container in the
+// environment network, falling back to the container name
+func containerAlias(o *dockertest.RunOptions, r *dockertest.Resource) string {
+	if o.Hostname != "" {
+		return o.Hostname
+	}
+	if o.Name != "" {
+		return o.Name
+	}
+	return strings.TrimPrefix(r.Container.Name, "/")
+}
+
+func imageName(o *dockertest.RunOptions) string {
+	return fmt.Sprintf("%s:%s", o.Repository, imageTag(o))
+}
+
+func imageTag(o *dockertest.RunOptions) string {
+	if o.Tag == "" {
+		return "latest"
+	}
+	return o.Tag
+}
+
+// resourceLabels returns the labels of the resources removed by the reaper,
+// resources kept to be reused aren't labeled
+func resourceLabels(opt Options) map[string]string {
+	if opt.Reuse {
+		return nil
+	}
+	return sessionLabels()
+}
+
+func getHostConfig(opt Options) func(*docker.HostConfig) {
+	var restartPolicyName string
+	if opt.RestartContainers {
+		restartPolicyName = "yes"
+	} else {
+		restartPolicyName = "no"
+	}
+
+	return func(config *docker.HostConfig) {
+		config.RestartPolicy = docker.RestartPolicy{Name: restartPolicyName}
 	}
 }
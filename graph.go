@@ -0,0 +1,165 @@
+package goit
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// node is a container in the dependency graph of an environment
+type node struct {
+	c    Container
+	deps []*node
+
+	// done is closed when the container finishes its startup,
+	// successfully or not
+	done   chan struct{}
+	failed bool
+}
+
+// buildGraph creates the dependency graph of the containers, adding the
+// dependencies that aren't in the list and skipping the ones that were
+// already started, nodes are returned in the order they were found
+func buildGraph(containers []Container, started func(Container) bool) ([]*node, error) {
+	nodes := []*node{}
+	byContainer := map[Container]*node{}
+
+	var add func(c Container) (*node, error)
+	add = func(c Container) (*node, error) {
+		if n, ok := byContainer[c]; ok {
+			return n, nil
+		}
+
+		n := &node{c: c, done: make(chan struct{})}
+		byContainer[c] = n
+		nodes = append(nodes, n)
+
+		for _, d := range dependenciesOf(c) {
+			if err := checkComparable(d); err != nil {
+				return nil, err
+			}
+			if started(d) {
+				continue
+			}
+			dn, err := add(d)
+			if err != nil {
+				return nil, err
+			}
+			n.deps = append(n.deps, dn)
+		}
+		return n, nil
+	}
+
+	for _, c := range containers {
+		if err := checkComparable(c); err != nil {
+			return nil, err
+		}
+		if started(c) {
+			continue
+		}
+		if _, err := add(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkCycles(nodes); err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// checkCycles returns an error describing the first cycle found in the graph
+func checkCycles(nodes []*node) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := map[*node]int{}
+	path := []*node{}
+
+	var visit func(n *node) error
+	visit = func(n *node) error {
+		switch state[n] {
+		case visited:
+			return nil
+		case visiting:
+			var names []string
+			for i := len(path) - 1; i >= 0; i-- {
+				names = append([]string{describe(path[i].c)}, names...)
+				if path[i] == n {
+					break
+				}
+			}
+			names = append(names, describe(n.c))
+			return newStartError(describe(n.c), PhaseDependencies,
+				errors.Errorf("dependency cycle: %s", strings.Join(names, " -> ")))
+		}
+
+		state[n] = visiting
+		path = append(path, n)
+		for _, d := range n.deps {
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[n] = visited
+		return nil
+	}
+
+	for _, n := range nodes {
+		if err := visit(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkComparable rejects the containers goit can't track, the environments
+// identify the containers by their values, e.g. structs with slices or
+// maps, which must be passed as pointers
+func checkComparable(c Container) error {
+	if c == nil {
+		return newStartError("", PhaseDependencies, errors.New("nil container"))
+	}
+	if t := reflect.TypeOf(c); !t.Comparable() {
+		return newStartError(describe(c), PhaseDependencies,
+			errors.Errorf("container of type %s can't be compared, pass a pointer to it", t))
+	}
+	return nil
+}
+
+func dependenciesOf(c Container) []Container {
+	if cd, ok := c.(containerWithDependencies); ok {
+		return cd.DependsOn()
+	}
+	return nil
+}
+
+// describe returns a name to identify the container in logs and errors,
+// the same before and after it is started: the container name or the
+// hostname it is reached by in the environment network, falling back to
+// its image and to its type
+func describe(c Container) string {
+	if cn, ok := c.(interface{ ContainerName() string }); ok && cn.ContainerName() != "" {
+		return cn.ContainerName()
+	}
+	if cr, ok := c.(containerFromRepository); ok {
+		if o, err := cr.Options(); err == nil && o != nil {
+			switch {
+			case o.Hostname != "":
+				return o.Hostname
+			case o.Name != "":
+				return o.Name
+			case o.Repository != "":
+				return imageName(o)
+			}
+		}
+	}
+	return fmt.Sprintf("%T", c)
+}
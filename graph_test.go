@@ -0,0 +1,115 @@
+package goit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ory/dockertest/v3"
+)
+
+type fakeContainer struct {
+	name string
+	deps []Container
+}
+
+func (c *fakeContainer) ContainerName() string {
+	return c.name
+}
+
+func (c *fakeContainer) DependsOn() []Container {
+	return c.deps
+}
+
+func (c *fakeContainer) AfterStart(context.Context, *dockertest.Resource) error {
+	return nil
+}
+
+func notStarted(Container) bool {
+	return false
+}
+
+func TestBuildGraphAddsMissingDependencies(t *testing.T) {
+	db := &fakeContainer{name: "db"}
+	app := &fakeContainer{name: "app", deps: []Container{db}}
+
+	nodes, err := buildGraph([]Container{app}, notStarted)
+	if err != nil {
+		t.Errorf("Unable to build graph: %v", err)
+		return
+	}
+
+	if len(nodes) != 2 {
+		t.Errorf("Invalid node count, expected 2, found: %d", len(nodes))
+		return
+	}
+
+	if nodes[0].deps[0] != nodes[1] || nodes[1].c != db {
+		t.Errorf("Invalid dependency, expected app to depend on db")
+	}
+}
+
+func TestBuildGraphSkipsStartedDependencies(t *testing.T) {
+	db := &fakeContainer{name: "db"}
+	app := &fakeContainer{name: "app", deps: []Container{db}}
+
+	nodes, err := buildGraph([]Container{app, db}, func(c Container) bool { return c == db })
+	if err != nil {
+		t.Errorf("Unable to build graph: %v", err)
+		return
+	}
+
+	if len(nodes) != 1 || len(nodes[0].deps) != 0 {
+		t.Errorf("Invalid graph, expected only app without dependencies, found: %d nodes", len(nodes))
+	}
+}
+
+func TestBuildGraphReportsCycles(t *testing.T) {
+	a := &fakeContainer{name: "a"}
+	b := &fakeContainer{name: "b", deps: []Container{a}}
+	c := &fakeContainer{name: "c", deps: []Container{b}}
+	a.deps = []Container{c}
+
+	_, err := buildGraph([]Container{a}, notStarted)
+
+	var se *StartError
+	if !errors.As(err, &se) {
+		t.Errorf("Invalid error, expected *StartError, found: %v", err)
+		return
+	}
+
+	if se.Phase != PhaseDependencies {
+		t.Errorf("Invalid phase, expected %s, found: %s", PhaseDependencies, se.Phase)
+	}
+
+	if !strings.Contains(se.Error(), "a -> c -> b -> a") {
+		t.Errorf("Invalid error, expected the cycle a -> c -> b -> a, found: %v", se)
+	}
+}
+
+// valueContainer can't be used as a map key, it must be passed as a pointer
+type valueContainer struct {
+	env []string
+}
+
+func (c valueContainer) AfterStart(context.Context, *dockertest.Resource) error {
+	return nil
+}
+
+func TestBuildGraphRejectsUncomparableContainers(t *testing.T) {
+	app := &fakeContainer{name: "app", deps: []Container{valueContainer{}}}
+
+	for _, cs := range [][]Container{{valueContainer{}}, {app}} {
+		_, err := buildGraph(cs, func(Container) bool { return false })
+
+		var se *StartError
+		if !errors.As(err, &se) || se.Phase != PhaseDependencies {
+			t.Errorf("expected a dependencies error, found: %v", err)
+		}
+	}
+
+	if HandleOf(valueContainer{}) != nil {
+		t.Errorf("expected no handle for a container goit can't start")
+	}
+}
@@ -0,0 +1,264 @@
+package goit
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// handles maps the started containers to their handles, it is used for
+// containers that don't embed a Handle
+var handles sync.Map
+
+// Handle gives access to a container after it is started by goit, embed
+// it in a Container to expose the container addresses, e.g.
+//
+//	type Container struct {
+//		goit.Handle
+//		...
+//	}
+type Handle struct {
+	mu       sync.RWMutex
+	pool     *dockertest.Pool
+	resource *dockertest.Resource
+	alias    string
+	host     string
+
+	// snapshot image of the container and if it was started from it
+	snapshotRepo string
+	snapshotTag  string
+	restored     bool
+
+	// pinnedImage is the commit used to recreate the container when it
+	// is stopped, it is removed with the container
+	pinnedImage string
+
+	// proxies route the container ports through other containers
+	proxies map[docker.Port]route
+
+	// logs captures the container output, followLogs resumes it after
+	// the container is started again
+	logs       *logCapture
+	followLogs func(since int64)
+}
+
+// route is a port of a container in front of another container port
+type route struct {
+	via  *Handle
+	port string
+}
+
+// handled is implemented by the containers embedding a Handle
+type handled interface {
+	handle() *Handle
+}
+
+func (h *Handle) handle() *Handle {
+	return h
+}
+
+// HandleOf returns the handle of a container started by goit, or nil if
+// the container wasn't started
+func HandleOf(c Container) *Handle {
+	if hc, ok := c.(handled); ok {
+		h := hc.handle()
+		if h.Resource() == nil {
+			return nil
+		}
+		return h
+	}
+
+	if c == nil || !reflect.TypeOf(c).Comparable() {
+		// goit doesn't start them, see checkComparable
+		return nil
+	}
+	if h, ok := handles.Load(c); ok {
+		return h.(*Handle)
+	}
+	return nil
+}
+
+// bindHandle sets the started resource to the container handle
+func bindHandle(c Container, p *dockertest.Pool, r *dockertest.Resource, alias string) *Handle {
+	var h *Handle
+	if hc, ok := c.(handled); ok {
+		h = hc.handle()
+	} else {
+		h = &Handle{}
+		handles.Store(c, h)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.pool = p
+	h.resource = r
+	h.alias = alias
+	h.host = dockerHostOf(p)
+	return h
+}
+
+// unbindHandle releases the container handle after it is purged
+func unbindHandle(c Container) {
+	var h *Handle
+	if hc, ok := c.(handled); ok {
+		h = hc.handle()
+	} else if v, ok := handles.Load(c); ok {
+		h = v.(*Handle)
+	}
+	handles.Delete(c)
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.pinnedImage != "" && h.pool != nil {
+		_ = h.pool.Client.RemoveImage(h.pinnedImage)
+	}
+	if h.logs != nil {
+		h.logs.close()
+	}
+	h.pool = nil
+	h.resource = nil
+	h.pinnedImage = ""
+	h.proxies = nil
+	h.followLogs = nil
+}
+
+// Resource returns the dockertest resource of the started container
+func (h *Handle) Resource() *dockertest.Resource {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.resource
+}
+
+// Pool returns the pool connected to the docker daemon running the container
+func (h *Handle) Pool() *dockertest.Pool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.pool
+}
+
+// Alias returns the name other containers use to reach this container
+// in the environment network
+func (h *Handle) Alias() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.alias
+}
+
+// NetworkAddress returns the address other containers use to reach the
+// container port inside the environment network, e.g. postgres:5432
+func (h *Handle) NetworkAddress(port string) string {
+	if via, viaPort, ok := h.proxy(port); ok {
+		return via.NetworkAddress(viaPort)
+	}
+	return net.JoinHostPort(h.Alias(), portNumber(port))
+}
+
+// SetProxy routes the connections to the container port through the
+// port of a proxy container, e.g. toxiproxy, the addresses returned by
+// the handle point to the proxy until RemoveProxy is called or the proxy
+// is stopped
+func (h *Handle) SetProxy(port string, proxy *Handle, proxyPort string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.proxies == nil {
+		h.proxies = map[docker.Port]route{}
+	}
+	h.proxies[portID(port)] = route{via: proxy, port: proxyPort}
+}
+
+// RemoveProxy connects the container port directly again
+func (h *Handle) RemoveProxy(port string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.proxies, portID(port))
+}
+
+// proxy returns the proxy in front of the port, if it is running
+func (h *Handle) proxy(port string) (*Handle, string, bool) {
+	h.mu.RLock()
+	rt, ok := h.proxies[portID(port)]
+	h.mu.RUnlock()
+
+	if !ok || rt.via.Resource() == nil {
+		return nil, "", false
+	}
+	return rt.via, rt.port, true
+}
+
+// Endpoint returns the host and port the host uses to reach the container
+// port, e.g. 5432/tcp, the host port is chosen by docker unless the
+// container binds it explicitly. The host is resolved by DockerHost
+// unless the port is bound to a specific address. Ports behind a proxy
+// return the proxy endpoint.
+func (h *Handle) Endpoint(port string) (host string, hostPort string) {
+	if via, viaPort, ok := h.proxy(port); ok {
+		return via.Endpoint(viaPort)
+	}
+
+	h.mu.RLock()
+	r, dh := h.resource, h.host
+	h.mu.RUnlock()
+	if r == nil {
+		return "", ""
+	}
+
+	id := string(portID(port))
+	hostPort = r.GetPort(id)
+	if hostPort == "" {
+		return "", ""
+	}
+
+	host = r.GetBoundIP(id)
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() || ip.IsLoopback() {
+		host = dh
+	}
+	return host, hostPort
+}
+
+// HostAddress returns the address the host uses to reach the container
+// port, e.g. localhost:49153
+func (h *Handle) HostAddress(port string) string {
+	host, hostPort := h.Endpoint(port)
+	if hostPort == "" {
+		return ""
+	}
+	return net.JoinHostPort(host, hostPort)
+}
+
+// URL returns the url the host uses to reach the container port with the
+// scheme, e.g. URL("http", "8080/tcp") returns http://localhost:49153
+func (h *Handle) URL(scheme, port string) string {
+	addr := h.HostAddress(port)
+	if addr == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s://%s", scheme, addr)
+}
+
+// portNumber returns the number of a container port, e.g. 5432 for 5432/tcp
+func portNumber(port string) string {
+	return strings.Split(string(portID(port)), "/")[0]
+}
+
+// portID normalizes a container port to the docker format, e.g. 5432 to 5432/tcp
+func portID(port string) docker.Port {
+	if !strings.Contains(port, "/") {
+		port += "/tcp"
+	}
+	return docker.Port(port)
+}
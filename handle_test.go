@@ -0,0 +1,50 @@
+package goit
+
+import (
+	"testing"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+func newTestHandle(alias, port, hostPort string) *Handle {
+	return &Handle{
+		alias: alias,
+		host:  "localhost",
+		resource: &dockertest.Resource{Container: &docker.Container{
+			NetworkSettings: &docker.NetworkSettings{
+				Ports: map[docker.Port][]docker.PortBinding{
+					docker.Port(port): {{HostIP: "0.0.0.0", HostPort: hostPort}},
+				},
+			},
+		}},
+	}
+}
+
+func TestHandleProxy(t *testing.T) {
+	db := newTestHandle("postgres", "5432/tcp", "49153")
+	proxy := newTestHandle("toxiproxy", "20000/tcp", "49160")
+
+	if addr := db.HostAddress("5432"); addr != "localhost:49153" {
+		t.Errorf("invalid host address, expected localhost:49153, found: %s", addr)
+	}
+
+	db.SetProxy("5432/tcp", proxy, "20000/tcp")
+	if addr := db.HostAddress("5432/tcp"); addr != "localhost:49160" {
+		t.Errorf("invalid proxied host address, expected localhost:49160, found: %s", addr)
+	}
+	if addr := db.NetworkAddress("5432/tcp"); addr != "toxiproxy:20000" {
+		t.Errorf("invalid proxied network address, expected toxiproxy:20000, found: %s", addr)
+	}
+
+	// a stopped proxy is bypassed
+	proxy.resource = nil
+	if addr := db.HostAddress("5432/tcp"); addr != "localhost:49153" {
+		t.Errorf("invalid host address without proxy, expected localhost:49153, found: %s", addr)
+	}
+
+	db.RemoveProxy("5432/tcp")
+	if addr := db.NetworkAddress("5432/tcp"); addr != "postgres:5432" {
+		t.Errorf("invalid network address, expected postgres:5432, found: %s", addr)
+	}
+}
@@ -0,0 +1,220 @@
+package goit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/pkg/errors"
+)
+
+const (
+	// envDockerHost overrides the host used to reach the ports mapped by
+	// the docker daemon, e.g. when it can't be detected
+	envDockerHost = "GOIT_DOCKER_HOST"
+
+	// dockerEnvFile exists when the process runs inside a docker container
+	dockerEnvFile = "/.dockerenv"
+)
+
+var (
+	dockerHostsMu sync.Mutex
+	dockerHosts   = map[string]string{}
+)
+
+// DockerHost returns the host where the docker daemon maps the container
+// ports, it is localhost for a local daemon, the DOCKER_HOST or docker
+// context host for a remote one, and the container gateway when the
+// tests run inside a docker container. GOIT_DOCKER_HOST overrides it.
+func DockerHost() (string, error) {
+	if h := os.Getenv(envDockerHost); h != "" {
+		return h, nil
+	}
+
+	p, err := newPool()
+	if err != nil {
+		return "", err
+	}
+	return dockerHostOf(p), nil
+}
+
+// newPool connects to the docker daemon from DOCKER_HOST or, when it is
+// not set, from the current docker context
+func newPool() (*dockertest.Pool, error) {
+	endpoint, tlsDir, err := contextEndpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsDir != "" {
+		return dockertest.NewTLSPool(endpoint, tlsDir)
+	}
+	return dockertest.NewPool(endpoint)
+}
+
+// dockerHostOf resolves the host reaching the ports mapped by the daemon
+// of the pool, the result is cached per daemon endpoint
+func dockerHostOf(p *dockertest.Pool) string {
+	if h := os.Getenv(envDockerHost); h != "" {
+		return h
+	}
+	if p == nil || p.Client == nil {
+		return "localhost"
+	}
+
+	endpoint := p.Client.Endpoint()
+
+	dockerHostsMu.Lock()
+	defer dockerHostsMu.Unlock()
+
+	if h, ok := dockerHosts[endpoint]; ok {
+		return h
+	}
+
+	h := resolveDockerHost(p, endpoint)
+	dockerHosts[endpoint] = h
+	return h
+}
+
+func resolveDockerHost(p *dockertest.Pool, endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err == nil {
+		switch u.Scheme {
+		case "tcp", "http", "https":
+			if h := u.Hostname(); h != "" {
+				return h
+			}
+		}
+	}
+
+	// the daemon is local to the host, but the ports it maps aren't
+	// reachable on localhost from inside a container
+	if !inContainer() {
+		return "localhost"
+	}
+
+	if n, err := p.Client.NetworkInfo("bridge"); err == nil {
+		for _, c := range n.IPAM.Config {
+			if c.Gateway != "" {
+				return c.Gateway
+			}
+		}
+	}
+	if gw, err := defaultGateway(); err == nil {
+		return gw
+	}
+	return "localhost"
+}
+
+// contextEndpoint returns the endpoint of the current docker context and
+// its tls material directory, or empty strings when DOCKER_HOST is set or
+// the default context is in use
+func contextEndpoint() (endpoint string, tlsDir string, err error) {
+	if os.Getenv("DOCKER_HOST") != "" {
+		return "", "", nil
+	}
+
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	name := os.Getenv("DOCKER_CONTEXT")
+	if name == "" {
+		var cfg struct {
+			CurrentContext string `json:"currentContext"`
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+		if err == nil {
+			_ = json.Unmarshal(b, &cfg)
+		}
+		name = cfg.CurrentContext
+	}
+	if name == "" || name == "default" {
+		return "", "", nil
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(sum[:])
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "contexts", "meta", id, "meta.json"))
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to read docker context: %s", name)
+	}
+
+	var meta struct {
+		Endpoints map[string]struct {
+			Host string `json:"Host"`
+		} `json:"Endpoints"`
+	}
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return "", "", errors.Wrapf(err, "failed to parse docker context: %s", name)
+	}
+
+	endpoint = meta.Endpoints["docker"].Host
+	if endpoint == "" {
+		return "", "", errors.Errorf("docker context has no docker endpoint: %s", name)
+	}
+
+	tlsDir = filepath.Join(dir, "contexts", "tls", id, "docker")
+	if _, err := os.Stat(filepath.Join(tlsDir, "ca.pem")); err != nil {
+		tlsDir = ""
+	}
+	return endpoint, tlsDir, nil
+}
+
+// inContainer reports if the process runs inside a docker container
+func inContainer() bool {
+	_, err := os.Stat(dockerEnvFile)
+	return err == nil
+}
+
+// defaultGateway reads the default route gateway from /proc/net/route
+func defaultGateway() (string, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return parseDefaultGateway(bufio.NewScanner(f))
+}
+
+// parseDefaultGateway finds the gateway of the default route, the route
+// table has the destination and gateway as little endian hex, e.g.
+//
+//	Iface	Destination	Gateway	...
+//	eth0	00000000	010011AC	...
+func parseDefaultGateway(s *bufio.Scanner) (string, error) {
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+
+		v, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid gateway: %s", fields[2])
+		}
+		ip := net.IPv4(byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+		return ip.String(), nil
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("default route not found")
+}
@@ -0,0 +1,87 @@
+package goit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDefaultGateway(t *testing.T) {
+	routes := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	000011AC	00000000	0001	0	0	0	0000FFFF	0	0	0
+eth0	00000000	010011AC	0003	0	0	0	00000000	0	0	0
+`
+	gw, err := parseDefaultGateway(bufio.NewScanner(strings.NewReader(routes)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gw != "172.17.0.1" {
+		t.Errorf("invalid gateway, expected 172.17.0.1, found: %s", gw)
+	}
+
+	_, err = parseDefaultGateway(bufio.NewScanner(strings.NewReader("Iface\tDestination\tGateway\n")))
+	if err == nil {
+		t.Errorf("expected an error without default route")
+	}
+}
+
+func TestContextEndpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sum := sha256.Sum256([]byte("remote"))
+	meta := filepath.Join(dir, "contexts", "meta", hex.EncodeToString(sum[:]))
+	if err := os.MkdirAll(meta, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(meta, "meta.json"), []byte(`{"Name":"remote","Endpoints":{"docker":{"Host":"tcp://10.0.0.5:2376"}}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"currentContext":"remote"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setenv(t, "DOCKER_HOST", "")
+	setenv(t, "DOCKER_CONTEXT", "")
+	setenv(t, "DOCKER_CONFIG", dir)
+
+	endpoint, tlsDir, err := contextEndpoint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if endpoint != "tcp://10.0.0.5:2376" {
+		t.Errorf("invalid endpoint, expected tcp://10.0.0.5:2376, found: %s", endpoint)
+	}
+	if tlsDir != "" {
+		t.Errorf("unexpected tls dir: %s", tlsDir)
+	}
+
+	setenv(t, "DOCKER_CONTEXT", "default")
+	endpoint, _, err = contextEndpoint()
+	if err != nil || endpoint != "" {
+		t.Errorf("expected the default endpoint, found: %s, err: %v", endpoint, err)
+	}
+}
+
+// setenv sets the environment variable until the test finishes
+func setenv(t *testing.T, key, value string) {
+	prev, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
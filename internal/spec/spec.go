@@ -0,0 +1,91 @@
+// Package spec holds the helpers shared by the declarative environment
+// definitions, e.g. compose files and goit.yaml
+package spec
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var variablePattern = regexp.MustCompile(`\$(?:\$|\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?[-?])([^}]*))?\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// Interpolate replaces the variables of a file, e.g. ${TAG},
+// ${TAG:-latest} or ${TAG:?error}, $$ is a literal $
+func Interpolate(s string, lookup func(string) (string, bool)) (string, error) {
+	var err error
+	out := variablePattern.ReplaceAllStringFunc(s, func(v string) string {
+		if v == "$$" {
+			return "$"
+		}
+
+		m := variablePattern.FindStringSubmatch(v)
+		name, op, arg := m[1], m[2], m[3]
+		if name == "" {
+			name = m[4]
+		}
+
+		value, ok := lookup(name)
+		unset := !ok || (strings.HasPrefix(op, ":") && value == "")
+		switch {
+		case unset && strings.HasSuffix(op, "-"):
+			return arg
+		case unset && strings.HasSuffix(op, "?"):
+			if err == nil {
+				err = errors.Errorf("required variable %s is missing: %s", name, arg)
+			}
+		}
+		return value
+	})
+	return out, err
+}
+
+// ReadEnvFile reads the variables of an env file, one KEY=value per line
+func ReadEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env := map[string]string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(line, "export "), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		v := strings.TrimSpace(parts[1])
+		if len(v) > 1 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		env[strings.TrimSpace(parts[0])] = v
+	}
+	return env, s.Err()
+}
+
+var sizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([kmgt]?)i?b?$`)
+
+// ParseSize parses a size in bytes, e.g. 1g, 512m or 1024
+func ParseSize(s string) (int64, error) {
+	m := sizePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, errors.Errorf("invalid size: %s", s)
+	}
+
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid size: %s", s)
+	}
+	unit := map[string]float64{"": 1, "k": 1 << 10, "m": 1 << 20, "g": 1 << 30, "t": 1 << 40}[m[2]]
+	return int64(v * unit), nil
+}
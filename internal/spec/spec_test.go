@@ -0,0 +1,42 @@
+package spec
+
+import (
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"SET": "value", "EMPTY": ""}
+	lookup := func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+
+	tests := map[string]string{
+		"$SET and ${SET}":       "value and value",
+		"${UNSET:-default}":     "default",
+		"${EMPTY:-default}":     "default",
+		"${EMPTY-default}":      "",
+		"$$SET":                 "$SET",
+		"${UNSET}":              "",
+		"cost: $$5, ${SET:-no}": "cost: $5, value",
+	}
+	for in, want := range tests {
+		got, err := Interpolate(in, lookup)
+		if err != nil || got != want {
+			t.Errorf("expected %q for %q, found: %q, err: %v", want, in, got, err)
+		}
+	}
+
+	if _, err := Interpolate("${UNSET:?must be set}", lookup); err == nil {
+		t.Errorf("expected an error for a missing required variable")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{"1024": 1024, "512m": 512 << 20, "1g": 1 << 30, "256M": 256 << 20, "1.5k": 1536}
+	for in, want := range tests {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("expected %d for %s, found: %d, err: %v", want, in, got, err)
+		}
+	}
+}
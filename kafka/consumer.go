@@ -24,7 +24,7 @@ func newConsumer(cm *kafka.ConfigMap) (*Consumer, error) {
 func (c *Consumer) Consume() ([]byte, error) {
 	m, err := c.c.ReadMessage(-1)
 	if err != nil {
-		log.Error(err, "error consuming message.")
+		log.Errorw("error consuming message", "err", err)
 		return []byte{}, err
 	}
 
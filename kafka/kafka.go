@@ -3,7 +3,6 @@ package kafka
 import (
 	"context"
 	"fmt"
-	"net"
 	"strconv"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
@@ -12,6 +11,7 @@ import (
 	"github.com/ory/dockertest/v3/docker"
 	"github.com/tclemos/goit"
 	"github.com/tclemos/goit/log"
+	"github.com/tclemos/goit/wait"
 )
 
 const (
@@ -22,14 +22,20 @@ const (
 // Params needed to start a kafka container
 type Params struct {
 	goit.ContainerParams
+
+	// BrokerPort and ClientPort bind kafka to fixed host ports, by
+	// default docker chooses free ones
 	BrokerPort int
-	ClientId   string
 	ClientPort int
-	Topics     []string
+
+	ClientId string
+	Topics   []string
 }
 
 // Container metadata to load a container for kafka
 type Container struct {
+	goit.Handle
+	goit.Spec
 	params    Params
 	Producer  *Producer
 	Consumers map[string]*Consumer
@@ -38,50 +44,49 @@ type Container struct {
 // NewContainer creates a new instance of Container
 func NewContainer(p Params) *Container {
 	return &Container{
-		params: p,
+		Spec:      p.Spec(),
+		params:    p,
+		Consumers: map[string]*Consumer{},
 	}
 }
 
 // Options to start a kafka container accordingly to the params
 func (c *Container) Options() (*dockertest.RunOptions, error) {
-
-	strPort := strconv.Itoa(c.getBrokerPort())
+	// docker chooses free host ports unless the params set them
 	pb := map[docker.Port][]docker.PortBinding{}
-	pb[docker.Port(fmt.Sprintf("%d/tcp", brokerPort))] = []docker.PortBinding{{
-		HostIP:   "0.0.0.0",
-		HostPort: strPort,
-	}}
-
-	strPort = strconv.Itoa(c.getClientPort())
-	pb[docker.Port(fmt.Sprintf("%d/tcp", clientPort))] = []docker.PortBinding{{
-		HostIP:   "0.0.0.0",
-		HostPort: strPort,
-	}}
+	bindPort(pb, brokerPort, c.params.BrokerPort)
+	bindPort(pb, clientPort, c.params.ClientPort)
 
 	repo, tag := c.params.GetRepoTag("confluentinc/cp-kafka", "5.3.0")
 	env := c.params.MergeEnv([]string{
 		"KAFKA_BROKER_ID=1",
-		fmt.Sprintf("KAFKA_LISTENERS=\"PLAINTEXT://0.0.0.0:%d,BROKER://0.0.0.0:%d", c.getClientPort(), c.getBrokerPort()),
-		"KAFKA_LISTENER_SECURITY_PROTOCOL_MAP=\"BROKER:PLAINTEXT,PLAINTEXT:PLAINTEXT\"",
+		fmt.Sprintf("KAFKA_LISTENERS=PLAINTEXT://0.0.0.0:%d,BROKER://0.0.0.0:%d", clientPort, brokerPort),
+		"KAFKA_LISTENER_SECURITY_PROTOCOL_MAP=BROKER:PLAINTEXT,PLAINTEXT:PLAINTEXT",
 		"KAFKA_INTER_BROKER_LISTENER_NAME=BROKER",
 		"KAFKA_OFFSETS_TOPIC_REPLICATION_FACTOR=1",
 	})
 
 	return &dockertest.RunOptions{
-		Repository:   repo,
-		Tag:          tag,
-		Env:          env,
+		Hostname:   c.params.GetAlias("kafka"),
+		Repository: repo,
+		Tag:        tag,
+		Auth:       c.params.Auth,
+		Env:        env,
+		Entrypoint: []string{"sh", "-c"},
+		Cmd:        []string{startCommand},
+		ExposedPorts: []string{
+			fmt.Sprintf("%d/tcp", brokerPort),
+			fmt.Sprintf("%d/tcp", clientPort),
+		},
 		PortBindings: pb,
 	}, nil
 }
 
 // AfterStart
 func (c *Container) AfterStart(ctx context.Context, r *dockertest.Resource) error {
+	l := log.FromContext(ctx)
 
-	id := fmt.Sprintf("%d/tcp", clientPort)
-	h := r.GetBoundIP(id)
-	p := r.GetPort(id)
-	host := net.JoinHostPort(h, p)
+	host := c.BootstrapServers()
 
 	clientId := uuid.New().String()
 	if len(c.params.ClientId) > 0 {
@@ -96,7 +101,7 @@ func (c *Container) AfterStart(ctx context.Context, r *dockertest.Resource) erro
 
 	ac, err := kafka.NewAdminClient(cm)
 	if err != nil {
-		log.Error(err, "failed to configure retries to check db connection")
+		l.Error("failed to configure retries to check db connection", "err", err)
 		return err
 	}
 	defer ac.Close()
@@ -115,7 +120,7 @@ func (c *Container) AfterStart(ctx context.Context, r *dockertest.Resource) erro
 
 		consumer, err := newConsumer(&ccm)
 		if err != nil {
-			log.Errorf(err, "failed to create a consumer for topic %s", topic)
+			l.Error("failed to create a consumer", "topic", topic, "err", err)
 			return err
 		}
 
@@ -128,32 +133,43 @@ func (c *Container) AfterStart(ctx context.Context, r *dockertest.Resource) erro
 		nil)
 
 	if err != nil {
-		log.Error(err, "failed to create kafka topics")
+		l.Error("failed to create kafka topics", "err", err)
 		return err
 	}
 
 	c.Producer, err = newProducer(cm)
 	if err != nil {
-		log.Error(err, "failed to create kafka producer")
+		l.Error("failed to create kafka producer", "err", err)
 		return err
 	}
 
 	return nil
 }
 
-func (c *Container) getBrokerPort() int {
-	bp := c.params.BrokerPort
-	if bp == 0 {
-		bp = brokerPort
+// WaitStrategy starts kafka advertising the host mapped port and waits
+// until the client port accepts connections, unless the params provide
+// another strategy to wait
+func (c *Container) WaitStrategy() wait.Strategy {
+	s := c.params.WaitFor
+	if s == nil {
+		s = wait.ForListeningPort(fmt.Sprintf("%d/tcp", clientPort))
 	}
+	return wait.ForAll(&listenersAdvertiser{c: c}, s)
+}
 
-	return bp
+// BootstrapServers returns the address to reach kafka from the host
+func (c *Container) BootstrapServers() string {
+	return c.HostAddress(fmt.Sprintf("%d/tcp", clientPort))
 }
 
-func (c *Container) getClientPort() int {
-	cp := c.params.ClientPort
-	if cp == 0 {
-		cp = clientPort
+// bindPort binds the container port to the host port, unless it is zero
+func bindPort(pb map[docker.Port][]docker.PortBinding, containerPort, hostPort int) {
+	if hostPort == 0 {
+		return
 	}
-	return cp
+
+	pb[docker.Port(fmt.Sprintf("%d/tcp", containerPort))] = []docker.PortBinding{{
+		HostIP:   "0.0.0.0",
+		HostPort: strconv.Itoa(hostPort),
+	}}
 }
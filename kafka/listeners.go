@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit"
+	"github.com/tclemos/goit/wait"
+)
+
+const (
+	startScript = "/tmp/goit_kafka.sh"
+
+	// startCommand holds kafka until the start script is written, the
+	// advertised listeners depend on the host port chosen by docker,
+	// which is only known after the container starts
+	startCommand = "while [ ! -f " + startScript + " ]; do sleep 0.1; done; . " + startScript
+)
+
+// listenersAdvertiser writes the start script with the advertised listeners
+type listenersAdvertiser struct {
+	c *Container
+}
+
+// WaitUntilReady writes the start script, releasing kafka to start
+func (a *listenersAdvertiser) WaitUntilReady(ctx context.Context, t wait.Target) error {
+	host := t.HostAddress(fmt.Sprintf("%d/tcp", clientPort))
+	if host == "" {
+		return errors.Errorf("kafka port %d/tcp is not mapped to the host", clientPort)
+	}
+
+	script := strings.Join([]string{
+		fmt.Sprintf("export KAFKA_ADVERTISED_LISTENERS=PLAINTEXT://%s,BROKER://%s", host, a.c.NetworkAddress(fmt.Sprintf("%d/tcp", brokerPort))),
+		"exec /etc/confluent/docker/run",
+	}, "\n")
+
+	res, err := a.c.Exec(ctx, []string{"sh", "-c", fmt.Sprintf("cat > %[1]s.tmp && mv %[1]s.tmp %[1]s", startScript)}, goit.ExecOptions{
+		Stdin: strings.NewReader(script),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to write kafka start script")
+	}
+	if res.ExitCode != 0 {
+		return errors.Errorf("failed to write kafka start script, exit code: %d, output: %s", res.ExitCode, res.Stderr)
+	}
+	return nil
+}
+
+// RejectProxy rejects routing kafka through a proxy, e.g. toxiproxy, the
+// clients connect to the advertised listeners after their first request,
+// which point to kafka itself, so the proxy would be skipped
+func (c *Container) RejectProxy(port string) error {
+	return errors.New("kafka clients connect to the advertised listeners, skipping the proxy")
+}
@@ -33,7 +33,7 @@ func (p *Producer) Produce(topic string, message []byte) error {
 	}, c)
 
 	if err != nil {
-		log.Errorf(err, "Failed to produce. topic: %s, message: %s", topic, message)
+		log.Errorw("failed to produce", "topic", topic, "message", message, "err", err)
 		return err
 	}
 
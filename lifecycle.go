@@ -0,0 +1,324 @@
+package goit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit/log"
+)
+
+// WaitUntilReady runs the wait strategy of a container started by goit
+// again, e.g. after it is restarted, containers without strategy are
+// considered ready
+func WaitUntilReady(ctx context.Context, c Container) error {
+	h := HandleOf(c)
+	if h == nil {
+		return errors.Errorf("container is not started: %s", describe(c))
+	}
+
+	s := waitStrategyOf(c)
+	if s == nil {
+		return nil
+	}
+	return s.WaitUntilReady(ctx, h)
+}
+
+// Pause suspends the processes of the container, the connections to it
+// hang until Unpause is called
+func (h *Handle) Pause(ctx context.Context) error {
+	p, r := h.Pool(), h.Resource()
+	if p == nil || r == nil {
+		return errors.New("container is not started")
+	}
+
+	err := p.Client.PauseContainer(r.Container.ID)
+	return errors.Wrapf(err, "failed to pause container: %s", r.Container.Name)
+}
+
+// Unpause resumes the processes suspended by Pause
+func (h *Handle) Unpause(ctx context.Context) error {
+	p, r := h.Pool(), h.Resource()
+	if p == nil || r == nil {
+		return errors.New("container is not started")
+	}
+
+	err := p.Client.UnpauseContainer(r.Container.ID)
+	return errors.Wrapf(err, "failed to unpause container: %s", r.Container.Name)
+}
+
+// Stop stops the container, waiting up to the timeout before killing
+// it, Start brings it back with the same host ports.
+//
+// Containers with host ports chosen by docker would lose their ports
+// when stopped, so the first time they are stopped goit creates them
+// again from a commit of their file system, with the same host ports,
+// volumes and aliases.
+func (h *Handle) Stop(ctx context.Context, timeout time.Duration) error {
+	p, r := h.Pool(), h.Resource()
+	if p == nil || r == nil {
+		return errors.New("container is not started")
+	}
+
+	return h.pin(ctx, func(id string) error {
+		// goit containers ignore their stop signal to support Expire, so
+		// the process is asked to end before docker waits for it
+		err := p.Client.KillContainer(docker.KillContainerOptions{Context: ctx, ID: id, Signal: docker.SIGTERM})
+		if err == nil {
+			err = p.Client.StopContainerWithContext(id, uint(timeout.Seconds()), ctx)
+		}
+		if _, ok := err.(*docker.ContainerNotRunning); ok {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to stop container: %s", r.Container.Name)
+	})
+}
+
+// Start starts the container stopped by Stop or Kill, use WaitUntilReady
+// to wait until it is ready again
+func (h *Handle) Start(ctx context.Context) error {
+	p, r := h.Pool(), h.Resource()
+	if p == nil || r == nil {
+		return errors.New("container is not started")
+	}
+
+	err := p.Client.StartContainerWithContext(r.Container.ID, nil, ctx)
+	if _, ok := err.(*docker.ContainerAlreadyRunning); !ok && err != nil {
+		return errors.Wrapf(err, "failed to start container: %s", r.Container.Name)
+	}
+
+	log.FromContext(ctx).Info("container started again", "container", r.Container.Name)
+	if err := h.refresh(ctx); err != nil {
+		return err
+	}
+
+	h.mu.RLock()
+	follow := h.followLogs
+	h.mu.RUnlock()
+	if follow != nil {
+		follow(time.Now().Unix())
+	}
+	return nil
+}
+
+// Restart stops and starts the container, see Stop and Start
+func (h *Handle) Restart(ctx context.Context, timeout time.Duration) error {
+	if err := h.Stop(ctx, timeout); err != nil {
+		return err
+	}
+	return h.Start(ctx)
+}
+
+// Kill sends the signal to the main process of the container, zero
+// means SIGKILL. Signals that end the process wait until it exits and
+// keep the host ports as Stop does, use a context with a deadline for
+// processes that may ignore them.
+func (h *Handle) Kill(ctx context.Context, sig docker.Signal) error {
+	p, r := h.Pool(), h.Resource()
+	if p == nil || r == nil {
+		return errors.New("container is not started")
+	}
+	if sig == 0 {
+		sig = docker.SIGKILL
+	}
+
+	kill := func(id string) error {
+		err := p.Client.KillContainer(docker.KillContainerOptions{
+			Context: ctx,
+			ID:      id,
+			Signal:  sig,
+		})
+		return errors.Wrapf(err, "failed to kill container: %s", r.Container.Name)
+	}
+
+	switch sig {
+	case docker.SIGKILL, docker.SIGTERM, docker.SIGINT, docker.SIGQUIT:
+		return h.pin(ctx, func(id string) error {
+			if err := kill(id); err != nil {
+				return err
+			}
+			_, err := p.Client.WaitContainerWithContext(id, ctx)
+			return errors.Wrapf(err, "failed to wait for container to exit: %s", r.Container.Name)
+		})
+	}
+	return kill(r.Container.ID)
+}
+
+// refresh reloads the container state, e.g. its host ports
+func (h *Handle) refresh(ctx context.Context) error {
+	p, r := h.Pool(), h.Resource()
+
+	c, err := p.Client.InspectContainerWithContext(r.Container.ID, ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to inspect container: %s", r.Container.Name)
+	}
+
+	// the resource is updated in place, it is shared with the environment
+	h.mu.Lock()
+	r.Container = c
+	h.mu.Unlock()
+	return nil
+}
+
+// pin stops the container with stop, containers that lose their host
+// ports when stopped are then recreated from a commit of their file
+// system with the ports bound, the new container is not started
+func (h *Handle) pin(ctx context.Context, stop func(id string) error) error {
+	p, r := h.Pool(), h.Resource()
+
+	c, err := p.Client.InspectContainerWithContext(r.Container.ID, ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to inspect container: %s", r.Container.Name)
+	}
+	if !needsPin(c) {
+		return stop(c.ID)
+	}
+
+	// the ports are read from c, stopped containers don't report them
+	if err := stop(c.ID); err != nil {
+		return err
+	}
+
+	log.FromContext(ctx).Info("recreating container to keep its host ports", "container", c.Name)
+
+	img, err := p.Client.CommitContainer(docker.CommitContainerOptions{
+		Context:   ctx,
+		Container: c.ID,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to commit container: %s", c.Name)
+	}
+
+	config := *c.Config
+	config.Image = img.ID
+
+	hc := *c.HostConfig
+	hc.AutoRemove = false
+	hc.PortBindings = c.NetworkSettings.Ports
+	hc.Mounts = keepVolumes(c)
+
+	nc, extra := endpointsOf(c)
+
+	err = p.Client.RemoveContainer(docker.RemoveContainerOptions{Context: ctx, ID: c.ID, Force: true})
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove container: %s", c.Name)
+	}
+
+	nw, err := p.Client.CreateContainer(docker.CreateContainerOptions{
+		Context:          ctx,
+		Name:             strings.TrimPrefix(c.Name, "/"),
+		Config:           &config,
+		HostConfig:       &hc,
+		NetworkingConfig: nc,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to recreate container: %s", c.Name)
+	}
+
+	for id, ep := range extra {
+		err := p.Client.ConnectNetwork(id, docker.NetworkConnectionOptions{
+			Context:        ctx,
+			Container:      nw.ID,
+			EndpointConfig: ep,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to connect container %s to network %s", c.Name, id)
+		}
+	}
+
+	h.mu.Lock()
+	r.Container = nw
+	h.pinnedImage = img.ID
+	h.mu.Unlock()
+
+	return h.refresh(ctx)
+}
+
+// needsPin reports if the container loses its host ports when it is stopped
+func needsPin(c *docker.Container) bool {
+	for port, bs := range c.NetworkSettings.Ports {
+		if len(bs) == 0 {
+			continue
+		}
+
+		pinned := false
+		for _, b := range c.HostConfig.PortBindings[port] {
+			if b.HostPort != "" && b.HostPort != "0" {
+				pinned = true
+			}
+		}
+		if !pinned {
+			return true
+		}
+	}
+	return false
+}
+
+// keepVolumes returns the mounts of the container with the anonymous
+// volumes referenced by name, so a new container gets the same data
+func keepVolumes(c *docker.Container) []docker.HostMount {
+	ms := append([]docker.HostMount{}, c.HostConfig.Mounts...)
+
+	mounted := map[string]int{}
+	for i, m := range ms {
+		mounted[m.Target] = i
+	}
+	for _, b := range c.HostConfig.Binds {
+		parts := strings.Split(b, ":")
+		if len(parts) > 1 {
+			mounted[parts[1]] = -1
+		}
+	}
+
+	for _, m := range c.Mounts {
+		if m.Name == "" {
+			continue
+		}
+
+		i, ok := mounted[m.Destination]
+		switch {
+		case !ok:
+			ms = append(ms, docker.HostMount{
+				Type:     string(MountVolume),
+				Source:   m.Name,
+				Target:   m.Destination,
+				ReadOnly: !m.RW,
+			})
+		case i >= 0 && ms[i].Type == string(MountVolume) && ms[i].Source == "":
+			ms[i].Source = m.Name
+		}
+	}
+	return ms
+}
+
+// endpointsOf returns the network endpoints of the container, docker
+// creates containers connected to a single network, the extra ones are
+// connected after the container is created
+func endpointsOf(c *docker.Container) (*docker.NetworkingConfig, map[string]*docker.EndpointConfig) {
+	nc := &docker.NetworkingConfig{EndpointsConfig: map[string]*docker.EndpointConfig{}}
+	extra := map[string]*docker.EndpointConfig{}
+
+	for name, n := range c.NetworkSettings.Networks {
+		if name == "bridge" && (c.HostConfig.NetworkMode == "" || c.HostConfig.NetworkMode == "default") {
+			continue
+		}
+
+		// docker adds the container id as alias, the new container gets its own
+		var aliases []string
+		for _, a := range n.Aliases {
+			if !strings.HasPrefix(c.ID, a) {
+				aliases = append(aliases, a)
+			}
+		}
+
+		ep := &docker.EndpointConfig{Aliases: aliases}
+		if len(nc.EndpointsConfig) == 0 {
+			nc.EndpointsConfig[name] = ep
+		} else {
+			extra[n.NetworkID] = ep
+		}
+	}
+	return nc, extra
+}
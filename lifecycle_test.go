@@ -0,0 +1,84 @@
+package goit
+
+import (
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+func TestNeedsPin(t *testing.T) {
+	ports := map[docker.Port][]docker.PortBinding{
+		"5432/tcp": {{HostIP: "0.0.0.0", HostPort: "49153"}},
+	}
+
+	tests := map[string]struct {
+		hc   docker.HostConfig
+		want bool
+	}{
+		"docker chosen": {docker.HostConfig{PortBindings: map[docker.Port][]docker.PortBinding{"5432/tcp": {{HostPort: ""}}}}, true},
+		"not bound":     {docker.HostConfig{}, true},
+		"bound":         {docker.HostConfig{PortBindings: ports}, false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			hc := tt.hc
+			c := &docker.Container{
+				HostConfig:      &hc,
+				NetworkSettings: &docker.NetworkSettings{Ports: ports},
+			}
+			if got := needsPin(c); got != tt.want {
+				t.Errorf("expected %v, found: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestKeepVolumes(t *testing.T) {
+	c := &docker.Container{
+		HostConfig: &docker.HostConfig{
+			Binds: []string{"/host:/config:ro"},
+			Mounts: []docker.HostMount{
+				{Type: "volume", Target: "/anonymous"},
+				{Type: "tmpfs", Target: "/tmp/data"},
+			},
+		},
+		Mounts: []docker.Mount{
+			{Source: "/host", Destination: "/config"},
+			{Name: "abc", Destination: "/anonymous", RW: true},
+			{Name: "def", Destination: "/var/lib/postgresql/data", RW: true},
+		},
+	}
+
+	ms := keepVolumes(c)
+	if len(ms) != 3 {
+		t.Fatalf("invalid mounts, expected 3, found: %+v", ms)
+	}
+	if ms[0].Source != "abc" {
+		t.Errorf("expected the anonymous volume by name, found: %+v", ms[0])
+	}
+	if ms[2].Source != "def" || ms[2].Target != "/var/lib/postgresql/data" {
+		t.Errorf("expected the image volume by name, found: %+v", ms[2])
+	}
+}
+
+func TestEndpointsOf(t *testing.T) {
+	c := &docker.Container{
+		ID:         "0123456789abcdef",
+		HostConfig: &docker.HostConfig{NetworkMode: "default"},
+		NetworkSettings: &docker.NetworkSettings{
+			Networks: map[string]docker.ContainerNetwork{
+				"bridge":    {NetworkID: "b"},
+				"goit-1234": {NetworkID: "n", Aliases: []string{"postgres", "0123456789ab"}},
+			},
+		},
+	}
+
+	nc, extra := endpointsOf(c)
+	if len(extra) != 0 {
+		t.Errorf("unexpected extra networks: %v", extra)
+	}
+	ep, ok := nc.EndpointsConfig["goit-1234"]
+	if !ok || len(ep.Aliases) != 1 || ep.Aliases[0] != "postgres" {
+		t.Errorf("invalid endpoints: %+v", nc.EndpointsConfig)
+	}
+}
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// envLevel sets the level of the goit messages, e.g. GOIT_LOG_LEVEL=warn
+const envLevel = "GOIT_LOG_LEVEL"
+
+// Level of a message, the loggers drop the messages below their level
+type Level int
+
+const (
+	// LevelDebug messages describe each step, e.g. the containers output
+	LevelDebug Level = iota
+
+	// LevelInfo messages describe the containers lifecycle
+	LevelInfo
+
+	// LevelWarn messages describe failures goit recovers from
+	LevelWarn
+
+	// LevelError messages describe failures to start or stop containers
+	LevelError
+
+	// LevelSilent drops all messages
+	LevelSilent
+)
+
+var levelNames = map[Level]string{
+	LevelDebug:  "debug",
+	LevelInfo:   "info",
+	LevelWarn:   "warn",
+	LevelError:  "error",
+	LevelSilent: "silent",
+}
+
+func (l Level) String() string {
+	if n, ok := levelNames[l]; ok {
+		return n
+	}
+	return "unknown"
+}
+
+// ParseLevel returns the level with the name, e.g. debug or silent
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "warning" {
+		s = "warn"
+	}
+	for l, n := range levelNames {
+		if n == s {
+			return l, nil
+		}
+	}
+	return LevelInfo, errors.Errorf("invalid log level: %q", s)
+}
+
+// EnvLevel returns the level set by GOIT_LOG_LEVEL, info when it isn't set
+// or is invalid
+func EnvLevel() Level {
+	l, err := ParseLevel(os.Getenv(envLevel))
+	if err != nil {
+		return LevelInfo
+	}
+	return l
+}
@@ -1,37 +1,270 @@
 package log
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	stdlog "log"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
 
+// Logger receives the goit messages, implement it to route them to
+// another logger. The kv are key value pairs describing the message, e.g.
+// "container", "postgres", "duration", time.Second
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// Printf writes a formatted message, e.g. testing.TB Logf
+type Printf func(format string, args ...interface{})
+
+type ctxKey struct{}
+
+var std = New(func(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stdout, "[goit]: "+format+"\n", args...)
+})
+
+// New creates a Logger writing the messages through printf as a line with
+// the level, the message and the fields as key=value, the messages below
+// the GOIT_LOG_LEVEL are dropped
+func New(printf Printf) Logger {
+	return NewWithLevel(printf, EnvLevel())
+}
+
+// NewWithLevel creates a Logger like New dropping the messages below the level
+func NewWithLevel(printf Printf, level Level) Logger {
+	if level >= LevelSilent {
+		return Silent()
+	}
+	return &printfLogger{printf: printf, level: level}
+}
+
+// NewTB creates a Logger writing to the test output, see New, the lines
+// are reported at the caller of the logger instead of this package
+func NewTB(t testing.TB) Logger {
+	level := EnvLevel()
+	if level >= LevelSilent {
+		return Silent()
+	}
+	return &tbLogger{t: t, level: level}
+}
+
+// NewStd creates a Logger writing to a standard library logger, see New
+func NewStd(l *stdlog.Logger) Logger {
+	return New(l.Printf)
+}
+
+// Silent returns a Logger dropping all messages
+func Silent() Logger {
+	return silent{}
+}
+
+// Default returns the Logger writing to stdout
+func Default() Logger {
+	return std
+}
+
+// With returns a Logger adding the kv to every message, e.g. the
+// container name
+func With(l Logger, kv ...interface{}) Logger {
+	if len(kv) == 0 {
+		return l
+	}
+	if w, ok := l.(*withLogger); ok {
+		return &withLogger{l: w.l, kv: append(append([]interface{}{}, w.kv...), kv...)}
+	}
+	return &withLogger{l: l, kv: kv}
+}
+
+// NewContext returns a copy of the context carrying the logger
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger carried by the context, or the default one
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(ctxKey{}).(Logger); ok && l != nil {
+		return l
+	}
+	return std
+}
+
+func Debug(msg string, kv ...interface{}) {
+	std.Debug(msg, kv...)
+}
+
+func Info(msg string, kv ...interface{}) {
+	std.Info(msg, kv...)
+}
+
+func Warn(msg string, kv ...interface{}) {
+	std.Warn(msg, kv...)
+}
+
+// Errorw writes an error message with its kv to the default logger, the
+// w stands for with fields, Error keeps its original signature
+func Errorw(msg string, kv ...interface{}) {
+	std.Error(msg, kv...)
+}
+
+// Log writes the args to the default logger at info level
+//
+// Deprecated: use Info with key value pairs
 func Log(args ...interface{}) {
-	prefix()
-	fmt.Print(args...)
-	fmt.Println()
+	Default().Info(fmt.Sprint(args...))
 }
 
+// Logf writes the formatted message to the default logger at info level
+//
+// Deprecated: use Info with key value pairs
 func Logf(format string, args ...interface{}) {
-	prefix()
-	fmt.Printf(format, args...)
-	fmt.Println()
+	Default().Info(fmt.Sprintf(format, args...))
 }
 
+// Error writes the args and the error to the default logger at error level
+//
+// Deprecated: use Errorw with "err", err
 func Error(err error, args ...interface{}) {
-	prefix()
-	fmt.Print(args...)
-	fmt.Print(" ")
-	fmt.Print("err: ")
-	fmt.Print(err)
-	fmt.Println()
+	Default().Error(fmt.Sprint(args...), "err", err)
 }
 
+// Errorf writes the formatted message and the error to the default logger
+// at error level
+//
+// Deprecated: use Errorw with "err", err
 func Errorf(err error, format string, args ...interface{}) {
-	prefix()
-	fmt.Printf(format, args...)
-	fmt.Print(" ")
-	fmt.Print("err: ")
-	fmt.Print(err)
-	fmt.Println()
+	Default().Error(fmt.Sprintf(format, args...), "err", err)
+}
+
+// printfLogger writes the messages at or above its level through a Printf
+type printfLogger struct {
+	printf Printf
+	level  Level
+}
+
+func (l *printfLogger) Debug(msg string, kv ...interface{}) {
+	l.log(LevelDebug, msg, kv)
+}
+
+func (l *printfLogger) Info(msg string, kv ...interface{}) {
+	l.log(LevelInfo, msg, kv)
 }
 
-func prefix() {
-	fmt.Print("[goit]: ")
+func (l *printfLogger) Warn(msg string, kv ...interface{}) {
+	l.log(LevelWarn, msg, kv)
 }
+
+func (l *printfLogger) Error(msg string, kv ...interface{}) {
+	l.log(LevelError, msg, kv)
+}
+
+func (l *printfLogger) log(level Level, msg string, kv []interface{}) {
+	if level < l.level {
+		return
+	}
+	l.printf("%s", format(level, msg, kv))
+}
+
+// format returns the message as a single line, e.g.
+// INFO container started container=postgres duration=1.2s
+func format(level Level, msg string, kv []interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToUpper(level.String()))
+	sb.WriteByte(' ')
+	sb.WriteString(msg)
+
+	for i := 0; i < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok || i+1 == len(kv) {
+			// a value without key is written as is, instead of being lost
+			key, i = "!BADKEY", i-1
+		}
+
+		sb.WriteByte(' ')
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		sb.WriteString(formatValue(kv[i+1]))
+	}
+	return sb.String()
+}
+
+func formatValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// tbLogger writes the messages at or above its level to the test output,
+// its methods are test helpers
+type tbLogger struct {
+	t     testing.TB
+	level Level
+}
+
+func (l *tbLogger) Debug(msg string, kv ...interface{}) {
+	l.t.Helper()
+	l.log(LevelDebug, msg, kv)
+}
+
+func (l *tbLogger) Info(msg string, kv ...interface{}) {
+	l.t.Helper()
+	l.log(LevelInfo, msg, kv)
+}
+
+func (l *tbLogger) Warn(msg string, kv ...interface{}) {
+	l.t.Helper()
+	l.log(LevelWarn, msg, kv)
+}
+
+func (l *tbLogger) Error(msg string, kv ...interface{}) {
+	l.t.Helper()
+	l.log(LevelError, msg, kv)
+}
+
+func (l *tbLogger) log(level Level, msg string, kv []interface{}) {
+	l.t.Helper()
+	if level < l.level {
+		return
+	}
+	l.t.Logf("%s", format(level, msg, kv))
+}
+
+// withLogger adds its kv to the messages of the underlying logger
+type withLogger struct {
+	l  Logger
+	kv []interface{}
+}
+
+func (w *withLogger) Debug(msg string, kv ...interface{}) {
+	w.l.Debug(msg, w.with(kv)...)
+}
+
+func (w *withLogger) Info(msg string, kv ...interface{}) {
+	w.l.Info(msg, w.with(kv)...)
+}
+
+func (w *withLogger) Warn(msg string, kv ...interface{}) {
+	w.l.Warn(msg, w.with(kv)...)
+}
+
+func (w *withLogger) Error(msg string, kv ...interface{}) {
+	w.l.Error(msg, w.with(kv)...)
+}
+
+func (w *withLogger) with(kv []interface{}) []interface{} {
+	return append(append([]interface{}{}, w.kv...), kv...)
+}
+
+type silent struct{}
+
+func (silent) Debug(string, ...interface{}) {}
+func (silent) Info(string, ...interface{})  {}
+func (silent) Warn(string, ...interface{})  {}
+func (silent) Error(string, ...interface{}) {}
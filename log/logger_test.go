@@ -0,0 +1,99 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		level Level
+		msg   string
+		kv    []interface{}
+		want  string
+	}{
+		{LevelInfo, "container started", nil, "INFO container started"},
+		{LevelDebug, "container started", []interface{}{"container", "postgres", "duration", time.Second}, "DEBUG container started container=postgres duration=1s"},
+		{LevelError, "failed", []interface{}{"err", fmt.Errorf("no such image")}, `ERROR failed err="no such image"`},
+		{LevelWarn, "odd", []interface{}{"key"}, "WARN odd !BADKEY=key"},
+		{LevelWarn, "empty", []interface{}{"key", ""}, `WARN empty key=""`},
+	}
+
+	for _, tt := range tests {
+		if got := format(tt.level, tt.msg, tt.kv); got != tt.want {
+			t.Errorf("expected %q, found: %q", tt.want, got)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	var lines []string
+	printf := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	l := With(NewWithLevel(printf, LevelWarn), "container", "postgres")
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn")
+	l.Error("error", "phase", "wait")
+
+	want := []string{"WARN warn container=postgres", "ERROR error container=postgres phase=wait"}
+	if fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Errorf("expected %q, found: %q", want, lines)
+	}
+
+	if _, ok := NewWithLevel(printf, LevelSilent).(silent); !ok {
+		t.Errorf("expected the silent level to drop all messages")
+	}
+}
+
+func TestEnvLevel(t *testing.T) {
+	defer os.Unsetenv(envLevel)
+
+	for v, want := range map[string]Level{"": LevelInfo, "DEBUG": LevelDebug, "warning": LevelWarn, "silent": LevelSilent, "loud": LevelInfo} {
+		os.Setenv(envLevel, v)
+		if got := EnvLevel(); got != want {
+			t.Errorf("expected %s for %q, found: %s", want, v, got)
+		}
+	}
+}
+
+// the functions of the first releases are kept for the existing callers
+var (
+	_ func(...interface{})                = Log
+	_ func(string, ...interface{})        = Logf
+	_ func(error, ...interface{})         = Error
+	_ func(error, string, ...interface{}) = Errorf
+	_ func(msg string, kv ...interface{}) = Errorw
+)
+
+// fakeTB records the lines and the helper calls of a logger
+type fakeTB struct {
+	testing.TB
+	helpers int
+	lines   []string
+}
+
+func (t *fakeTB) Helper() {
+	t.helpers++
+}
+
+func (t *fakeTB) Logf(format string, args ...interface{}) {
+	t.lines = append(t.lines, fmt.Sprintf(format, args...))
+}
+
+func TestNewTB(t *testing.T) {
+	tb := &fakeTB{}
+	NewTB(tb).Info("container started", "container", "postgres")
+
+	if len(tb.lines) != 1 || tb.lines[0] != "INFO container started container=postgres" {
+		t.Errorf("invalid lines: %q", tb.lines)
+	}
+	// Info and log are both marked, so the line is reported at the caller
+	if tb.helpers != 2 {
+		t.Errorf("expected the logger methods to be test helpers, found %d helper calls", tb.helpers)
+	}
+}
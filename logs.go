@@ -0,0 +1,304 @@
+package goit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ahmetb/dlog"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/tclemos/goit/log"
+)
+
+const (
+	// envLogsDir sets the directory where the containers output is written
+	envLogsDir = "GOIT_LOGS_DIR"
+
+	// maxCapturedLogs is the output kept in memory for each container,
+	// the oldest lines are dropped once it is reached
+	maxCapturedLogs = 1 << 20
+
+	// failureLogLines is the number of lines of each container written
+	// to the test output when a test fails
+	failureLogLines = 50
+
+	// maxLogLine is the length kept of each line of the containers output,
+	// longer lines are truncated with truncatedSuffix
+	maxLogLine = 64 * 1024
+
+	truncatedSuffix = " [truncated]"
+)
+
+// logCapture keeps the output of a container in memory and, optionally,
+// in a file
+type logCapture struct {
+	mu   sync.Mutex
+	buf  []byte
+	file *os.File
+}
+
+// newLogCapture creates the capture of the container output, writing it
+// to a file in the logs dir when it is set
+func newLogCapture(ctx context.Context, dir string, alias string, r *dockertest.Resource) *logCapture {
+	c := &logCapture{}
+	if dir == "" {
+		return c
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.FromContext(ctx).Warn("failed to create logs dir", "dir", dir, "err", err)
+		return c
+	}
+
+	id := r.Container.ID
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	p := filepath.Join(dir, fmt.Sprintf("%s-%s.log", alias, id))
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.FromContext(ctx).Warn("failed to create log file", "file", p, "err", err)
+		return c
+	}
+
+	c.file = f
+	return c
+}
+
+// write appends a line of the container output
+func (c *logCapture) write(line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.buf = append(c.buf, line...)
+	c.buf = append(c.buf, '\n')
+	if over := len(c.buf) - maxCapturedLogs; over > 0 {
+		if i := bytes.IndexByte(c.buf[over:], '\n'); i >= 0 {
+			over += i + 1
+		}
+		c.buf = append([]byte{}, c.buf[over:]...)
+	}
+
+	if c.file != nil {
+		_, _ = io.WriteString(c.file, line+"\n")
+	}
+}
+
+func (c *logCapture) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return string(c.buf)
+}
+
+// tail returns the last n lines of the output
+func (c *logCapture) tail(n int) []string {
+	lines := strings.Split(strings.TrimSuffix(c.String(), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// close closes the log file, the output is still kept in memory
+func (c *logCapture) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file != nil {
+		_ = c.file.Close()
+		c.file = nil
+	}
+}
+
+// Logs returns the output of a container started by goit, stdout and
+// stderr interleaved, e.g. to assert the container logged a message
+func Logs(c Container) string {
+	h := HandleOf(c)
+	if h == nil {
+		return ""
+	}
+	return h.Logs()
+}
+
+// Logs returns the output of the container, see Logs
+func (h *Handle) Logs() string {
+	lc := h.logCapture()
+	if lc == nil {
+		return ""
+	}
+	return lc.String()
+}
+
+func (h *Handle) logCapture() *logCapture {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.logs
+}
+
+// DumpLogsOnFailure writes the last lines of the containers output to the
+// test output when the test fails, StartT does it for its containers
+func DumpLogsOnFailure(t testing.TB, containers ...Container) {
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+		for _, c := range containers {
+			dumpLogs(t, c)
+		}
+	})
+}
+
+func dumpLogs(t testing.TB, c Container) {
+	h := HandleOf(c)
+	if h == nil || h.logCapture() == nil {
+		return
+	}
+
+	lines := h.logCapture().tail(failureLogLines)
+	if len(lines) == 0 {
+		return
+	}
+	t.Logf("last %d lines of %s:\n%s", len(lines), describe(c), strings.Join(lines, "\n"))
+}
+
+// tailLogs returns the last n lines of the container output read from
+// docker, the capture of the output may not have caught up yet
+func tailLogs(p *dockertest.Pool, r *dockertest.Resource, n int) []string {
+	var b bytes.Buffer
+	err := p.Client.Logs(docker.LogsOptions{
+		Container:    r.Container.ID,
+		Stdout:       true,
+		Stderr:       true,
+		Tail:         strconv.Itoa(n),
+		OutputStream: &b,
+		ErrorStream:  &b,
+	})
+	out := strings.TrimSuffix(b.String(), "\n")
+	if err != nil || out == "" {
+		return nil
+	}
+	return strings.Split(out, "\n")
+}
+
+// logsDir returns the directory where the containers output is written,
+// empty when it is written to the goit log
+func logsDir(opt Options) string {
+	if opt.LogsDir != "" {
+		return opt.LogsDir
+	}
+	return os.Getenv(envLogsDir)
+}
+
+// captureLogs starts capturing the container output, the capture is
+// resumed when the container is started again, see Handle.Start
+func captureLogs(ctx context.Context, p *dockertest.Pool, h *Handle, since int64, cfg startConfig) {
+	dir := logsDir(cfg.opt)
+	lc := newLogCapture(ctx, dir, h.Alias(), h.Resource())
+	follow := func(since int64) {
+		redirectLogs(cfg.logCtx, cfg.logs, p, h.Resource(), since, lc, dir == "")
+	}
+
+	h.mu.Lock()
+	h.logs = lc
+	h.followLogs = follow
+	h.mu.Unlock()
+
+	follow(since)
+}
+
+// redirectLogs streams the container output to the capture and, when
+// toLog is set, to the goit log until the context is canceled, since is
+// the unix time of the first line and wg is done when the output is fully
+// written
+func redirectLogs(ctx context.Context, wg *sync.WaitGroup, p *dockertest.Pool, r *dockertest.Resource, since int64, lc *logCapture, toLog bool) {
+	pr, pw := io.Pipe()
+
+	go func() {
+		err := p.Client.Logs(docker.LogsOptions{
+			Context: ctx,
+
+			Stderr: true,
+			Stdout: true,
+
+			Follow:      true,
+			Timestamps:  true,
+			RawTerminal: true,
+			Since:       since,
+
+			Container: r.Container.ID,
+
+			OutputStream: pw,
+			ErrorStream:  pw,
+		})
+
+		if err != nil && ctx.Err() == nil {
+			log.FromContext(ctx).Warn("failed to attach log", "container", r.Container.Name, "err", err)
+		}
+		pw.Close()
+	}()
+
+	wg.Add(1)
+	go func(n string) {
+		defer wg.Done()
+		l := log.FromContext(ctx)
+		err := readLines(dlog.NewReader(pr), func(line string) {
+			lc.write(line)
+			if toLog {
+				l.Debug(line, "container", n)
+			}
+		})
+		// unblocks the docker attach when the output can't be read
+		pr.CloseWithError(err)
+	}(r.Container.Name)
+}
+
+// readLines calls fn with each line of the reader until it ends, lines
+// longer than maxLogLine are truncated instead of ending the output
+func readLines(r io.Reader, fn func(line string)) error {
+	br := bufio.NewReader(r)
+	var (
+		line      []byte
+		truncated bool
+	)
+	for {
+		chunk, isPrefix, err := br.ReadLine()
+		if err != nil {
+			if len(line) > 0 {
+				fn(string(line))
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		room := maxLogLine - len(line)
+		if len(chunk) > room {
+			chunk, truncated = chunk[:room], true
+		}
+		line = append(line, chunk...)
+		if isPrefix {
+			continue
+		}
+
+		if truncated {
+			line = append(line, truncatedSuffix...)
+		}
+		fn(string(line))
+		line, truncated = line[:0], false
+	}
+}
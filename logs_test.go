@@ -0,0 +1,114 @@
+package goit
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+func TestLogCapture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := &dockertest.Resource{Container: &docker.Container{ID: "0123456789abcdef"}}
+	lc := newLogCapture(context.Background(), dir, "postgres", r)
+	for _, l := range []string{"first", "second", "third"} {
+		lc.write(l)
+	}
+	lc.close()
+
+	if s := lc.String(); s != "first\nsecond\nthird\n" {
+		t.Errorf("invalid captured output: %q", s)
+	}
+	if tail := lc.tail(2); strings.Join(tail, ",") != "second,third" {
+		t.Errorf("invalid tail: %v", tail)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "postgres-0123456789ab.log"))
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if string(b) != "first\nsecond\nthird\n" {
+		t.Errorf("invalid log file: %q", b)
+	}
+}
+
+func TestLogCaptureLimit(t *testing.T) {
+	lc := &logCapture{}
+	line := strings.Repeat("x", 1023)
+	for i := 0; i < 2*maxCapturedLogs/1024; i++ {
+		lc.write(line)
+	}
+	lc.write("last")
+
+	s := lc.String()
+	if len(s) > maxCapturedLogs {
+		t.Errorf("expected at most %d bytes, found: %d", maxCapturedLogs, len(s))
+	}
+	if !strings.HasPrefix(s, line+"\n") || !strings.HasSuffix(s, "\nlast\n") {
+		t.Errorf("expected whole lines to be kept")
+	}
+}
+
+func TestReadLinesTruncatesLongLines(t *testing.T) {
+	long := strings.Repeat("x", maxLogLine*2)
+	r := strings.NewReader(long + "\nshort\nlast")
+
+	var lines []string
+	if err := readLines(r, func(l string) { lines = append(lines, l) }); err != nil {
+		t.Fatalf("failed to read lines: %v", err)
+	}
+
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, found: %d", len(lines))
+	}
+	if lines[0] != long[:maxLogLine]+truncatedSuffix {
+		t.Errorf("expected the long line to be truncated, found %d bytes", len(lines[0]))
+	}
+	if lines[1] != "short" || lines[2] != "last" {
+		t.Errorf("expected the following lines to be kept, found: %q", lines[1:])
+	}
+}
+
+func TestTailLogs(t *testing.T) {
+	var tail string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tail = r.URL.Query().Get("tail")
+
+		// a stderr frame, see stdcopy
+		frame := []byte("FATAL: role \"app\" does not exist\n")
+		header := []byte{2, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[4:], uint32(len(frame)))
+		w.Write(append(header, frame...))
+	}))
+	defer srv.Close()
+
+	client, err := docker.NewClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &dockertest.Pool{Client: client}
+	r := &dockertest.Resource{Container: &docker.Container{ID: "abc"}}
+
+	lines := tailLogs(p, r, failureLogLines)
+	if len(lines) != 1 || lines[0] != `FATAL: role "app" does not exist` || tail != "50" {
+		t.Fatalf("expected the last line of the output, found: %q, tail: %s", lines, tail)
+	}
+
+	err = &StartError{Container: "db", Phase: PhaseWait, Err: context.DeadlineExceeded, Logs: lines}
+	if !strings.Contains(err.Error(), "last 1 lines of db:\nFATAL") {
+		t.Errorf("expected the logs in the error, found: %s", err)
+	}
+}
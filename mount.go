@@ -0,0 +1,182 @@
+package goit
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit/log"
+)
+
+// MountType is the kind of storage mounted in a container
+type MountType string
+
+const (
+	// MountBind mounts a host path, for remote docker daemons the path
+	// must exist on the daemon host
+	MountBind MountType = "bind"
+
+	// MountVolume mounts a docker volume
+	MountVolume MountType = "volume"
+
+	// MountTmpfs mounts an in-memory file system
+	MountTmpfs MountType = "tmpfs"
+)
+
+// Mount is a storage mounted in a container when it is created
+type Mount struct {
+	Type MountType
+
+	// Source is the host path of bind mounts and the name of volumes,
+	// volumes without name are removed with the container
+	Source string
+
+	// Target is the absolute path of the mount in the container
+	Target string
+
+	ReadOnly bool
+
+	// TmpfsSize limits the size of tmpfs mounts in bytes, zero is unlimited
+	TmpfsSize int64
+}
+
+// BindMount mounts the host path, relative paths are resolved from the
+// working directory, e.g. the package directory of the tests
+func BindMount(source, target string) Mount {
+	return Mount{Type: MountBind, Source: source, Target: target}
+}
+
+// VolumeMount mounts the named volume, volumes that don't exist are
+// created and removed when the environment stops, use an empty name for
+// a volume that is removed with the container
+func VolumeMount(name, target string) Mount {
+	return Mount{Type: MountVolume, Source: name, Target: target}
+}
+
+// TmpfsMount mounts an in-memory file system limited to size bytes, e.g.
+// for postgres data on /var/lib/postgresql/data
+func TmpfsMount(target string, size int64) Mount {
+	return Mount{Type: MountTmpfs, Target: target, TmpfsSize: size}
+}
+
+// containerWithMounts represents a docker container with storage mounted
+type containerWithMounts interface {
+	Container
+
+	// Mounts of the container
+	Mounts() []Mount
+}
+
+// mountsOf returns the mounts of the container
+func mountsOf(c Container) []Mount {
+	if cm, ok := c.(containerWithMounts); ok {
+		return cm.Mounts()
+	}
+	return nil
+}
+
+// volumeSet tracks the volumes created by an environment
+type volumeSet struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (s *volumeSet) add(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.names = append(s.names, name)
+}
+
+// take returns the volumes and clears the set
+func (s *volumeSet) take() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := s.names
+	s.names = nil
+	return names
+}
+
+// hostMounts converts the mounts to docker mounts, creating the named
+// volumes that don't exist yet, the volumes are tracked to be removed
+// when the environment stops unless they are kept to be reused
+func hostMounts(ctx context.Context, p *dockertest.Pool, ms []Mount, labels map[string]string, track bool, cfg startConfig) ([]docker.HostMount, error) {
+	var hms []docker.HostMount
+	for _, m := range ms {
+		hm := docker.HostMount{
+			Type:     string(m.Type),
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		}
+
+		switch m.Type {
+		case MountBind:
+			src, err := filepath.Abs(m.Source)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid bind mount source: %s", m.Source)
+			}
+			hm.Source = src
+		case MountVolume:
+			if m.Source == "" {
+				break
+			}
+			created, err := ensureVolume(ctx, p, m.Source, labels)
+			if err != nil {
+				return nil, err
+			}
+			if created && track && cfg.volumes != nil {
+				cfg.volumes.add(m.Source)
+			}
+		case MountTmpfs:
+			hm.Source = ""
+			if m.TmpfsSize > 0 {
+				hm.TempfsOptions = &docker.TempfsOptions{SizeBytes: m.TmpfsSize}
+			}
+		default:
+			return nil, errors.Errorf("invalid mount type %q for: %s", m.Type, m.Target)
+		}
+
+		hms = append(hms, hm)
+	}
+	return hms, nil
+}
+
+// ensureVolume creates the volume if it doesn't exist yet
+func ensureVolume(ctx context.Context, p *dockertest.Pool, name string, labels map[string]string) (bool, error) {
+	_, err := p.Client.InspectVolume(name)
+	if err == nil {
+		return false, nil
+	}
+	if err != docker.ErrNoSuchVolume {
+		return false, errors.Wrapf(err, "failed to inspect volume: %s", name)
+	}
+
+	_, err = p.Client.CreateVolume(docker.CreateVolumeOptions{
+		Context: ctx,
+		Name:    name,
+		Labels:  labels,
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to create volume: %s", name)
+	}
+
+	log.FromContext(ctx).Info("volume created", "volume", name)
+	return true, nil
+}
+
+// removeVolumes removes the volumes from docker, logging the failures
+func removeVolumes(l log.Logger, p *dockertest.Pool, names []string) {
+	for _, n := range names {
+		err := p.Client.RemoveVolumeWithOptions(docker.RemoveVolumeOptions{Name: n, Force: true})
+		if err != nil {
+			l.Warn("could not remove volume", "volume", n, "err", err)
+		} else {
+			l.Debug("volume removed", "volume", n)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package goit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestHostMounts(t *testing.T) {
+	ms := []Mount{
+		BindMount("testdata", "/data"),
+		TmpfsMount("/tmp/data", 1024),
+		VolumeMount("", "/var/lib/data"),
+	}
+
+	hms, err := hostMounts(context.Background(), nil, ms, nil, true, startConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hms) != len(ms) {
+		t.Fatalf("invalid mounts, expected %d, found: %d", len(ms), len(hms))
+	}
+
+	abs, _ := filepath.Abs("testdata")
+	if hms[0].Type != "bind" || hms[0].Source != abs {
+		t.Errorf("invalid bind mount, expected source %s, found: %+v", abs, hms[0])
+	}
+	if hms[1].Type != "tmpfs" || hms[1].TempfsOptions == nil || hms[1].TempfsOptions.SizeBytes != 1024 {
+		t.Errorf("invalid tmpfs mount: %+v", hms[1])
+	}
+	if hms[2].Type != "volume" || hms[2].Source != "" {
+		t.Errorf("invalid anonymous volume mount: %+v", hms[2])
+	}
+
+	_, err = hostMounts(context.Background(), nil, []Mount{{Type: "nfs", Target: "/data"}}, nil, true, startConfig{})
+	if err == nil {
+		t.Errorf("expected an error for an invalid mount type")
+	}
+}
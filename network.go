@@ -0,0 +1,68 @@
+package goit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/tclemos/goit/log"
+)
+
+// containerWithAliases represents a docker container reached by other
+// names in the environment network, besides its alias
+type containerWithAliases interface {
+	Container
+
+	// Aliases of the container in the environment network
+	Aliases() []string
+}
+
+// aliasesOf returns the extra aliases of the container
+func aliasesOf(c Container) []string {
+	if ca, ok := c.(containerWithAliases); ok {
+		return ca.Aliases()
+	}
+	return nil
+}
+
+// createNetwork creates the user-defined network shared by the containers
+// of an environment, so they can reach each other by their aliases
+func createNetwork(ctx context.Context, p *dockertest.Pool, labels map[string]string) (*dockertest.Network, error) {
+	l := log.FromContext(ctx)
+	n := fmt.Sprintf("goit-%s", uuid.New().String())
+	l.Debug("creating network", "network", n)
+
+	net, err := p.CreateNetwork(n, func(o *docker.CreateNetworkOptions) {
+		o.Labels = sessionLabels()
+		for k, v := range labels {
+			o.Labels[k] = v
+		}
+	})
+	if err != nil {
+		l.Error("failed to create network", "network", n, "err", err)
+		return nil, err
+	}
+
+	return net, nil
+}
+
+// removeNetwork removes the network of an environment, logging the failures
+func removeNetwork(l log.Logger, p *dockertest.Pool, net *dockertest.Network) {
+	l.Debug("removing network", "network", net.Network.Name)
+	if err := p.RemoveNetwork(net); err != nil {
+		l.Warn("could not remove network", "network", net.Network.Name, "err", err)
+	} else {
+		l.Debug("network removed", "network", net.Network.Name)
+	}
+}
+
+// disconnectNetwork disconnects a container kept running from the network
+// of an environment, so the network can be removed
+func disconnectNetwork(l log.Logger, p *dockertest.Pool, net *dockertest.Network, r *dockertest.Resource) {
+	err := p.Client.DisconnectNetwork(net.Network.ID, docker.NetworkConnectionOptions{Container: r.Container.ID, Force: true})
+	if err != nil {
+		l.Warn("could not disconnect container from network", "container", r.Container.Name, "network", net.Network.Name, "err", err)
+	}
+}
@@ -0,0 +1,266 @@
+package goit
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit/log"
+)
+
+const (
+	// envOffline disables the image pulls when set to true
+	envOffline = "GOIT_OFFLINE"
+
+	// dockerHub is the registry of the images without registry host
+	dockerHub = "docker.io"
+)
+
+// PullPolicy tells when the image of a container is pulled
+type PullPolicy string
+
+const (
+	// PullIfNotPresent pulls the image when it isn't available locally,
+	// it is the default
+	PullIfNotPresent PullPolicy = "if-not-present"
+
+	// PullAlways pulls the image before each start, e.g. for latest tags
+	PullAlways PullPolicy = "always"
+
+	// PullNever uses the local images only, the start fails when the
+	// image isn't available locally
+	PullNever PullPolicy = "never"
+)
+
+// containerWithPullPolicy represents a docker container that tells goit
+// when to pull its image
+type containerWithPullPolicy interface {
+	Container
+
+	// PullPolicy of the container image, empty uses the environment policy
+	PullPolicy() PullPolicy
+}
+
+// pullPolicyOf returns the pull policy of the container, offline
+// environments never pull
+func pullPolicyOf(c Container, opt Options) PullPolicy {
+	if isOffline(opt) {
+		return PullNever
+	}
+	if cp, ok := c.(containerWithPullPolicy); ok && cp.PullPolicy() != "" {
+		return cp.PullPolicy()
+	}
+	if opt.PullPolicy != "" {
+		return opt.PullPolicy
+	}
+	return PullIfNotPresent
+}
+
+// isOffline reports if the environment must not pull images
+func isOffline(opt Options) bool {
+	return opt.Offline || strings.EqualFold(os.Getenv(envOffline), "true")
+}
+
+// pullImage pulls the image of the container accordingly to the policy,
+// with the auth of the options or, when it isn't set, of the docker config
+func pullImage(ctx context.Context, p *dockertest.Pool, o *dockertest.RunOptions, policy PullPolicy) error {
+	n := imageName(o)
+	_, err := p.Client.InspectImage(n)
+	present := err == nil
+
+	switch {
+	case policy == PullNever && !present:
+		return errors.Errorf("image %s is not available locally and pulling is disabled, pull it with: docker pull %s", n, n)
+	case policy != PullAlways && present:
+		return nil
+	}
+
+	auth := o.Auth
+	if auth == (docker.AuthConfiguration{}) {
+		auth, err = registryAuth(registryOf(o.Repository))
+		if err != nil {
+			log.FromContext(ctx).Warn("failed to read registry auth, pulling without it", "image", n, "err", err)
+		}
+	}
+
+	log.FromContext(ctx).Info("pulling image", "image", n, "phase", PhasePull)
+	err = p.Client.PullImage(docker.PullImageOptions{
+		Context:    ctx,
+		Repository: o.Repository,
+		Tag:        imageTag(o),
+	}, auth)
+	return errors.Wrapf(err, "failed to pull image: %s", n)
+}
+
+// registryOf returns the registry host of the repository, e.g. ghcr.io
+// for ghcr.io/shopify/toxiproxy and docker.io for postgres
+func registryOf(repository string) string {
+	i := strings.Index(repository, "/")
+	if i < 0 {
+		return dockerHub
+	}
+
+	host := repository[:i]
+	if !strings.ContainsAny(host, ".:") && host != "localhost" {
+		return dockerHub
+	}
+	if host == "index.docker.io" || host == "registry-1.docker.io" {
+		return dockerHub
+	}
+	return host
+}
+
+// dockerConfig is the part of the docker config.json with the registry auth
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth string `json:"auth"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// registryAuth returns the auth of the registry from the docker config,
+// docker login stores it in the config or in a credential helper, an
+// empty auth is returned when there is none
+func registryAuth(registry string) (docker.AuthConfiguration, error) {
+	cfg, err := readDockerConfig()
+	if err != nil || cfg == nil {
+		return docker.AuthConfiguration{}, err
+	}
+
+	helper := cfg.CredsStore
+	for k, h := range cfg.CredHelpers {
+		if normalizeRegistry(k) == registry {
+			helper = h
+		}
+	}
+
+	for k, a := range cfg.Auths {
+		if normalizeRegistry(k) != registry || a.Auth == "" {
+			continue
+		}
+
+		b, err := base64.StdEncoding.DecodeString(a.Auth)
+		if err != nil {
+			return docker.AuthConfiguration{}, errors.Wrapf(err, "invalid auth for registry: %s", registry)
+		}
+		parts := strings.SplitN(string(b), ":", 2)
+		if len(parts) != 2 {
+			return docker.AuthConfiguration{}, errors.Errorf("invalid auth for registry: %s", registry)
+		}
+		return docker.AuthConfiguration{Username: parts[0], Password: parts[1], ServerAddress: k}, nil
+	}
+
+	if helper != "" {
+		return helperAuth(helper, registry)
+	}
+	return docker.AuthConfiguration{}, nil
+}
+
+// readDockerConfig reads the config.json from DOCKER_CONFIG or ~/.docker,
+// nil is returned when it doesn't exist
+func readDockerConfig() (*dockerConfig, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg dockerConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to parse docker config")
+	}
+	return &cfg, nil
+}
+
+// helperAuth gets the auth of the registry from the docker credential
+// helper, e.g. osxkeychain runs docker-credential-osxkeychain
+func helperAuth(helper, registry string) (docker.AuthConfiguration, error) {
+	server := registry
+	if registry == dockerHub {
+		server = "https://index.docker.io/v1/"
+	}
+
+	var out, stderr bytes.Buffer
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// the helpers fail when they have no credentials for the server
+		if strings.Contains(out.String()+stderr.String(), "credentials not found") {
+			return docker.AuthConfiguration{}, nil
+		}
+		return docker.AuthConfiguration{}, errors.Wrapf(err, "credential helper %s failed: %s", helper, strings.TrimSpace(stderr.String()))
+	}
+
+	var creds struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &creds); err != nil {
+		return docker.AuthConfiguration{}, errors.Wrapf(err, "invalid answer of credential helper: %s", helper)
+	}
+	return docker.AuthConfiguration{Username: creds.Username, Password: creds.Secret, ServerAddress: server}, nil
+}
+
+// normalizeRegistry returns the registry host of a docker config key,
+// e.g. docker.io for https://index.docker.io/v1/
+func normalizeRegistry(key string) string {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	if i := strings.Index(key, "/"); i >= 0 {
+		key = key[:i]
+	}
+	return registryOf(key + "/")
+}
+
+// buildAuth returns the auth of the registries in the docker config, so
+// docker build can pull private base images
+func buildAuth(ctx context.Context) docker.AuthConfigurations {
+	auths := docker.AuthConfigurations{Configs: map[string]docker.AuthConfiguration{}}
+
+	cfg, err := readDockerConfig()
+	if err != nil || cfg == nil {
+		return auths
+	}
+
+	registries := map[string]bool{}
+	for k := range cfg.Auths {
+		registries[normalizeRegistry(k)] = true
+	}
+	for k := range cfg.CredHelpers {
+		registries[normalizeRegistry(k)] = true
+	}
+
+	for reg := range registries {
+		a, err := registryAuth(reg)
+		if err != nil {
+			log.FromContext(ctx).Warn("failed to read registry auth", "registry", reg, "err", err)
+			continue
+		}
+		if a.ServerAddress != "" {
+			auths.Configs[a.ServerAddress] = a
+		}
+	}
+	return auths
+}
@@ -0,0 +1,74 @@
+package goit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+func TestRegistryOf(t *testing.T) {
+	tests := map[string]string{
+		"postgres":                  "docker.io",
+		"localstack/localstack":     "docker.io",
+		"ghcr.io/shopify/toxiproxy": "ghcr.io",
+		"localhost:5000/app":        "localhost:5000",
+		"index.docker.io/library/a": "docker.io",
+	}
+	for repo, want := range tests {
+		if got := registryOf(repo); got != want {
+			t.Errorf("expected %s for %s, found: %s", want, repo, got)
+		}
+	}
+
+	if got := normalizeRegistry("https://index.docker.io/v1/"); got != "docker.io" {
+		t.Errorf("expected docker.io, found: %s", got)
+	}
+}
+
+func TestRegistryAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// user:secret and ci:token
+	cfg := `{"auths": {
+		"https://index.docker.io/v1/": {"auth": "dXNlcjpzZWNyZXQ="},
+		"ghcr.io": {"auth": "Y2k6dG9rZW4="}
+	}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, "DOCKER_CONFIG", dir)
+
+	tests := map[string]docker.AuthConfiguration{
+		"docker.io": {Username: "user", Password: "secret", ServerAddress: "https://index.docker.io/v1/"},
+		"ghcr.io":   {Username: "ci", Password: "token", ServerAddress: "ghcr.io"},
+		"quay.io":   {},
+	}
+	for reg, want := range tests {
+		got, err := registryAuth(reg)
+		if err != nil || got != want {
+			t.Errorf("expected %+v for %s, found: %+v, err: %v", want, reg, got, err)
+		}
+	}
+}
+
+func TestPullPolicyOf(t *testing.T) {
+	setenv(t, envOffline, "")
+	if got := pullPolicyOf(nil, Options{}); got != PullIfNotPresent {
+		t.Errorf("expected %s by default, found: %s", PullIfNotPresent, got)
+	}
+	if got := pullPolicyOf(nil, Options{PullPolicy: PullAlways}); got != PullAlways {
+		t.Errorf("expected the environment policy, found: %s", got)
+	}
+
+	setenv(t, envOffline, "true")
+	if got := pullPolicyOf(nil, Options{PullPolicy: PullAlways}); got != PullNever {
+		t.Errorf("expected offline environments to never pull, found: %s", got)
+	}
+}
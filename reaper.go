@@ -0,0 +1,156 @@
+package goit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit/log"
+)
+
+const (
+	// labelSession identifies the resources created by a test process
+	labelSession = "goit.session"
+
+	// labelReaper identifies the reaper containers
+	labelReaper = "goit.reaper"
+
+	// envReaperDisabled disables the reaper when set to true
+	envReaperDisabled = "GOIT_REAPER_DISABLED"
+
+	// DefaultReaperImage is the image used to remove the resources of
+	// test processes that ended without stopping their environments
+	DefaultReaperImage = "testcontainers/ryuk:0.3.3"
+
+	reaperPort = "8080/tcp"
+)
+
+var (
+	sessionID = uuid.New().String()
+
+	reaperMu   sync.Mutex
+	reaperConn net.Conn
+)
+
+// SessionID identifies the resources created by this process, they are
+// labeled with it so the reaper can remove them once the process ends
+func SessionID() string {
+	return sessionID
+}
+
+// sessionLabels returns the labels added to the resources of this process
+func sessionLabels() map[string]string {
+	return map[string]string{labelSession: sessionID}
+}
+
+// startReaper starts the reaper sidecar once per process, it removes the
+// containers, networks and images labeled with the session id after this
+// process disappears, e.g. when the test binary is killed
+func startReaper(ctx context.Context, p *dockertest.Pool, opt Options) error {
+	if reaperDisabled(opt) {
+		return nil
+	}
+
+	reaperMu.Lock()
+	defer reaperMu.Unlock()
+
+	if reaperConn != nil {
+		return nil
+	}
+
+	img := opt.ReaperImage
+	if img == "" {
+		img = DefaultReaperImage
+	}
+	repo, tag := splitImage(img)
+
+	l := log.FromContext(ctx)
+	l.Debug("starting reaper", "session", sessionID)
+	o := &dockertest.RunOptions{
+		Repository:   repo,
+		Tag:          tag,
+		ExposedPorts: []string{reaperPort},
+		Mounts:       []string{"/var/run/docker.sock:/var/run/docker.sock"},
+		Labels:       map[string]string{labelReaper: sessionID},
+	}
+	if err := pullImage(ctx, p, o, pullPolicyOf(nil, opt)); err != nil {
+		return errors.Wrap(err, "failed to pull reaper image")
+	}
+
+	r, err := p.RunWithOptions(o, func(hc *docker.HostConfig) {
+		hc.AutoRemove = true
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to start reaper")
+	}
+
+	conn, err := connectReaper(net.JoinHostPort(dockerHostOf(p), r.GetPort(reaperPort)))
+	if err != nil {
+		_ = p.Purge(r)
+		return err
+	}
+
+	reaperConn = conn
+	l.Debug("reaper started", "container", r.Container.Name)
+	return nil
+}
+
+// connectReaper registers the session filter in the reaper, the
+// connection must be kept open while the process is alive
+func connectReaper(addr string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	for i := 0; i < 10; i++ {
+		conn, err = net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to reaper at %s", addr)
+	}
+
+	filter := fmt.Sprintf("label=%s=%s\n", labelSession, sessionID)
+	if _, err := conn.Write([]byte(filter)); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to register session in reaper")
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	ack, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || strings.TrimSpace(ack) != "ACK" {
+		conn.Close()
+		return nil, errors.Errorf("reaper didn't acknowledge the session, answer: %q, err: %v", ack, err)
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	return conn, nil
+}
+
+func reaperDisabled(opt Options) bool {
+	if opt.DisableReaper {
+		return true
+	}
+	v, _ := strconv.ParseBool(os.Getenv(envReaperDisabled))
+	return v
+}
+
+// splitImage splits an image reference into repository and tag
+func splitImage(img string) (repo, tag string) {
+	i := strings.LastIndex(img, ":")
+	if i < 0 || strings.Contains(img[i:], "/") {
+		return img, "latest"
+	}
+	return img[:i], img[i+1:]
+}
@@ -0,0 +1,104 @@
+package goit
+
+import (
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// cpuPeriod is the CFS period used to convert Resources.CPUs into a quota
+const cpuPeriod = 100000
+
+// Resources limits what a container can use from the docker host, zero
+// values are unlimited or the docker defaults
+type Resources struct {
+	// Memory is the memory limit in bytes, e.g. 512 << 20
+	Memory int64
+
+	// CPUShares is the CPU weight relative to the other containers,
+	// docker defaults to 1024
+	CPUShares int64
+
+	// CPUs is the number of CPUs the container can use, e.g. 0.5, it sets
+	// CPUQuota and CPUPeriod when they aren't set
+	CPUs float64
+
+	// CPUQuota is the CPU time in microseconds the container can use in
+	// each CPUPeriod
+	CPUQuota  int64
+	CPUPeriod int64
+
+	// PidsLimit is the maximum number of processes, -1 is unlimited
+	PidsLimit int64
+
+	// Ulimits of the container processes, e.g. nofile
+	Ulimits []docker.ULimit
+
+	// ShmSize is the size of /dev/shm in bytes, docker defaults to 64MB
+	ShmSize int64
+}
+
+// containerWithResources represents a docker container with resource limits
+type containerWithResources interface {
+	Container
+
+	// Resources of the container, they override the environment defaults
+	Resources() Resources
+}
+
+// resourcesOf returns the resource limits of the container
+func resourcesOf(c Container) Resources {
+	if cr, ok := c.(containerWithResources); ok {
+		return cr.Resources()
+	}
+	return Resources{}
+}
+
+// merge returns the resources with the zero values replaced by the
+// defaults, the ulimits are merged by name
+func (r Resources) merge(defaults Resources) Resources {
+	m := r
+	if m.Memory == 0 {
+		m.Memory = defaults.Memory
+	}
+	if m.CPUShares == 0 {
+		m.CPUShares = defaults.CPUShares
+	}
+	if m.CPUs == 0 && m.CPUQuota == 0 {
+		m.CPUs, m.CPUQuota, m.CPUPeriod = defaults.CPUs, defaults.CPUQuota, defaults.CPUPeriod
+	}
+	if m.PidsLimit == 0 {
+		m.PidsLimit = defaults.PidsLimit
+	}
+	if m.ShmSize == 0 {
+		m.ShmSize = defaults.ShmSize
+	}
+
+	m.Ulimits = append([]docker.ULimit{}, r.Ulimits...)
+	for _, d := range defaults.Ulimits {
+		found := false
+		for _, u := range r.Ulimits {
+			found = found || u.Name == d.Name
+		}
+		if !found {
+			m.Ulimits = append(m.Ulimits, d)
+		}
+	}
+	if len(m.Ulimits) == 0 {
+		m.Ulimits = nil
+	}
+	return m
+}
+
+// apply sets the resource limits in the host config
+func (r Resources) apply(hc *docker.HostConfig) {
+	hc.Memory = r.Memory
+	hc.CPUShares = r.CPUShares
+	hc.CPUQuota = r.CPUQuota
+	hc.CPUPeriod = r.CPUPeriod
+	if r.CPUs > 0 && r.CPUQuota == 0 {
+		hc.CPUPeriod = cpuPeriod
+		hc.CPUQuota = int64(r.CPUs * cpuPeriod)
+	}
+	hc.PidsLimit = r.PidsLimit
+	hc.Ulimits = r.Ulimits
+	hc.ShmSize = r.ShmSize
+}
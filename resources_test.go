@@ -0,0 +1,58 @@
+package goit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+func TestResourcesMerge(t *testing.T) {
+	defaults := Resources{
+		Memory:    512 << 20,
+		CPUs:      1,
+		PidsLimit: 100,
+		Ulimits:   []docker.ULimit{{Name: "nofile", Soft: 1024, Hard: 1024}, {Name: "nproc", Soft: 64, Hard: 64}},
+	}
+	r := Resources{
+		Memory:   1 << 30,
+		CPUQuota: 50000,
+		Ulimits:  []docker.ULimit{{Name: "nofile", Soft: 4096, Hard: 4096}},
+	}
+
+	want := Resources{
+		Memory:    1 << 30,
+		CPUQuota:  50000,
+		PidsLimit: 100,
+		Ulimits:   []docker.ULimit{{Name: "nofile", Soft: 4096, Hard: 4096}, {Name: "nproc", Soft: 64, Hard: 64}},
+	}
+	if got := r.merge(defaults); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, found: %+v", want, got)
+	}
+
+	if got := (Resources{}).merge(Resources{}); !reflect.DeepEqual(got, Resources{}) {
+		t.Errorf("expected no resources, found: %+v", got)
+	}
+}
+
+func TestResourcesApply(t *testing.T) {
+	var hc docker.HostConfig
+	Resources{Memory: 1 << 30, CPUs: 0.5, ShmSize: 256 << 20}.apply(&hc)
+
+	if hc.Memory != 1<<30 || hc.ShmSize != 256<<20 {
+		t.Errorf("invalid memory limits: %d, %d", hc.Memory, hc.ShmSize)
+	}
+	if hc.CPUPeriod != 100000 || hc.CPUQuota != 50000 {
+		t.Errorf("expected half a CPU, found: %d of %d", hc.CPUQuota, hc.CPUPeriod)
+	}
+}
+
+func TestReuseHashResources(t *testing.T) {
+	o := &dockertest.RunOptions{Repository: "postgres", Tag: "13"}
+	h1, _ := reuseHash(nil, o, createOptions{})
+	h2, _ := reuseHash(nil, o, createOptions{resources: Resources{Memory: 1 << 30}})
+	if h1 == h2 {
+		t.Errorf("expected another hash for other resources")
+	}
+}
@@ -0,0 +1,232 @@
+package goit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/ory/dockertest/v3/docker/pkg/fileutils"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit/log"
+)
+
+const (
+	// labelReuseHash identifies a reusable container by the hash of its options
+	labelReuseHash = "goit.reuse.hash"
+
+	// envRecreate forces the reusable containers to be recreated when set to true
+	envRecreate = "GOIT_RECREATE"
+)
+
+// reuseHash returns a hash identifying the options used to create a container,
+// containers created with the same options can be reused
+func reuseHash(b *dockertest.BuildOptions, o *dockertest.RunOptions, co createOptions) (string, error) {
+	ro := *o
+	ro.Networks = nil
+	ro.Auth = docker.AuthConfiguration{}
+	ro.Env = append([]string{}, o.Env...)
+	sort.Strings(ro.Env)
+
+	var content string
+	if b != nil {
+		d, err := contextDigest(b)
+		if err != nil {
+			return "", err
+		}
+		content = d
+	}
+
+	j, err := json.Marshal(struct {
+		Build     *dockertest.BuildOptions
+		Context   string `json:",omitempty"`
+		Run       dockertest.RunOptions
+		Mounts    []Mount    `json:",omitempty"`
+		Resources *Resources `json:",omitempty"`
+	}{b, content, ro, co.mounts, resourcesHash(co.resources)})
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.Sum256(j)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// contextDigest returns a digest of the build context sent to docker, the
+// dockerfile and the files not excluded by the .dockerignore, so changes
+// to them create a new container instead of reusing the stale one
+func contextDigest(b *dockertest.BuildOptions) (string, error) {
+	dir := b.ContextDir
+	if dir == "" {
+		dir = "."
+	}
+
+	var patterns []string
+	ignore, err := os.ReadFile(filepath.Join(dir, ".dockerignore"))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for _, l := range strings.Split(string(ignore), "\n") {
+		if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "#") {
+			patterns = append(patterns, l)
+		}
+	}
+	pm, err := fileutils.NewPatternMatcher(patterns)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != b.Dockerfile && rel != ".dockerignore" {
+			excluded, err := pm.Matches(rel)
+			if err != nil {
+				return err
+			}
+			if excluded {
+				// exclusions may include files of the excluded dir back
+				if d.IsDir() && !pm.Exclusions() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		fmt.Fprintf(h, "%s\x00%s\x00", rel, d.Type())
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s\x00", target)
+		case d.Type().IsRegular():
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read build context: %s", dir)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resourcesHash returns the resources hashed with the options, nil when
+// they are not set, so the hash of containers without limits is kept
+func resourcesHash(r Resources) *Resources {
+	if reflect.DeepEqual(r, Resources{}) {
+		return nil
+	}
+	return &r
+}
+
+// findReusable returns a running and healthy container created with the
+// same options, containers that can't be reused are removed
+func findReusable(ctx context.Context, p *dockertest.Pool, hash string, recreate bool) (*dockertest.Resource, error) {
+	cs, err := p.Client.ListContainers(docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {labelReuseHash + "=" + hash},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range cs {
+		r, err := getResource(p, c.ID)
+		if err == nil && !recreate && isHealthy(r.Container) {
+			return r, nil
+		}
+
+		log.FromContext(ctx).Info("removing container that can't be reused", "container", strings.Join(c.Names, ","))
+		err = p.Client.RemoveContainer(docker.RemoveContainerOptions{ID: c.ID, Force: true, RemoveVolumes: true})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// attachReused connects a reused container to the environment network
+func attachReused(p *dockertest.Pool, r *dockertest.Resource, cfg startConfig, aliases []string) error {
+	if cfg.network == nil {
+		return nil
+	}
+
+	err := p.Client.ConnectNetwork(cfg.network.Network.ID, docker.NetworkConnectionOptions{
+		Container: r.Container.ID,
+		EndpointConfig: &docker.EndpointConfig{
+			Aliases: aliases,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	c, err := p.Client.InspectContainer(r.Container.ID)
+	if err != nil {
+		return err
+	}
+	r.Container = c
+	return nil
+}
+
+// isReusable tells if the container was created to be reused, those
+// containers are kept running when the environment stops
+func isReusable(r *dockertest.Resource) bool {
+	return r.Container.Config != nil && r.Container.Config.Labels[labelReuseHash] != ""
+}
+
+func isHealthy(c *docker.Container) bool {
+	if !c.State.Running {
+		return false
+	}
+	return c.State.Health.Status == "" || c.State.Health.Status == "healthy"
+}
+
+// shouldRecreate tells if the reusable containers must be recreated
+func shouldRecreate(opt Options) bool {
+	if opt.Recreate {
+		return true
+	}
+	v, _ := strconv.ParseBool(os.Getenv(envRecreate))
+	return v
+}
+
+// withLabel returns a copy of the labels with the label added
+func withLabel(labels map[string]string, k, v string) map[string]string {
+	l := map[string]string{}
+	for lk, lv := range labels {
+		l[lk] = lv
+	}
+	l[k] = v
+	return l
+}
@@ -0,0 +1,53 @@
+package goit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ory/dockertest/v3"
+)
+
+func TestReuseHashFollowsBuildContext(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("Dockerfile", "FROM alpine\nCOPY . /app\n")
+	write("main.go", "package main\n")
+	write(".dockerignore", "tmp\n")
+	write("tmp/cache", "a")
+
+	b := &dockertest.BuildOptions{ContextDir: dir + string(filepath.Separator), Dockerfile: "Dockerfile"}
+	o := &dockertest.RunOptions{Name: "app", Repository: "app"}
+	hash := func() string {
+		h, err := reuseHash(b, o, createOptions{})
+		if err != nil {
+			t.Fatalf("Unable to hash options: %v", err)
+		}
+		return h
+	}
+
+	first := hash()
+	write("tmp/cache", "b")
+	if hash() != first {
+		t.Errorf("expected the ignored files not to change the hash")
+	}
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	second := hash()
+	if second == first {
+		t.Errorf("expected a changed file of the context to change the hash")
+	}
+
+	write("Dockerfile", "FROM alpine:3\nCOPY . /app\n")
+	if hash() == second {
+		t.Errorf("expected a changed dockerfile to change the hash")
+	}
+}
@@ -0,0 +1,165 @@
+package goit
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// interruptTimeout limits how long the startups in progress are awaited
+// after a signal, before the environment is stopped
+const interruptTimeout = 30 * time.Second
+
+// signalHandler tracks an environment in the process signal handler
+type signalHandler struct {
+	// interrupted is closed when a signal is received, canceling the
+	// startups in progress
+	interrupted chan struct{}
+}
+
+// activeSignals is the process signal handler, a single one for all the
+// environments, so the signal is raised again only after all of them stop
+var activeSignals = &signalRegistry{envs: map[*Environment]*signalHandler{}}
+
+// signalRegistry stops the active environments when the process is
+// interrupted, e.g. with Ctrl-C, so their containers aren't left behind
+type signalRegistry struct {
+	mu      sync.Mutex
+	envs    map[*Environment]*signalHandler
+	signals chan os.Signal
+	done    chan struct{}
+}
+
+// handleSignals registers the environment in the signal handler while it
+// is active
+func (e *Environment) handleSignals(opt Options) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if opt.DisableSignalHandler || e.signals != nil {
+		return
+	}
+
+	e.signals = &signalHandler{interrupted: make(chan struct{})}
+	activeSignals.register(e, e.signals)
+}
+
+// stopHandlingSignals unregisters the environment from the signal
+// handler, it must be called with the environment locked
+func (e *Environment) stopHandlingSignals() {
+	if e.signals == nil {
+		return
+	}
+
+	activeSignals.unregister(e)
+	e.signals = nil
+}
+
+// register adds the environment, installing the handler for the first one
+func (r *signalRegistry) register(e *Environment, h *signalHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.envs[e] = h
+	if r.signals != nil {
+		return
+	}
+
+	r.signals = make(chan os.Signal, 1)
+	r.done = make(chan struct{})
+	signal.Notify(r.signals, os.Interrupt, syscall.SIGTERM)
+	go r.wait(r.signals, r.done)
+}
+
+// unregister removes the environment, uninstalling the handler after the
+// last one
+func (r *signalRegistry) unregister(e *Environment) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.envs, e)
+	if len(r.envs) == 0 {
+		r.uninstall()
+	}
+}
+
+// uninstall restores the default signal behavior, it must be called with
+// the registry locked
+func (r *signalRegistry) uninstall() {
+	if r.signals == nil {
+		return
+	}
+
+	signal.Stop(r.signals)
+	close(r.done)
+	r.signals, r.done = nil, nil
+}
+
+// wait stops all the active environments when a signal is received, then
+// raises it again, so the process ends as it would without goit
+func (r *signalRegistry) wait(signals chan os.Signal, done <-chan struct{}) {
+	var sig os.Signal
+	select {
+	case sig = <-signals:
+	case <-done:
+		return
+	}
+
+	r.mu.Lock()
+	envs := make(map[*Environment]*signalHandler, len(r.envs))
+	for e, h := range r.envs {
+		envs[e] = h
+	}
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for e, h := range envs {
+		close(h.interrupted)
+
+		wg.Add(1)
+		go func(e *Environment) {
+			defer wg.Done()
+			e.log.Warn("stopping containers", "signal", sig)
+			e.waitStartups(interruptTimeout)
+			e.Stop()
+		}(e)
+	}
+	wg.Wait()
+
+	// environments registered meanwhile installed their own handler
+	r.mu.Lock()
+	if r.signals == signals {
+		r.uninstall()
+	}
+	r.mu.Unlock()
+
+	raise(sig)
+}
+
+// interrupted returns a channel closed when the process is interrupted
+func (e *Environment) interrupted() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.signals == nil {
+		return nil
+	}
+	return e.signals.interrupted
+}
+
+// waitStartups waits until the startups in progress finish or the timeout is reached
+func (e *Environment) waitStartups(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		e.starting.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		e.log.Warn("startups still in progress, stopping anyway", "timeout", timeout)
+	}
+}
@@ -0,0 +1,25 @@
+package goit
+
+import "testing"
+
+func TestSignalRegistry(t *testing.T) {
+	r := &signalRegistry{envs: map[*Environment]*signalHandler{}}
+	e1, e2 := NewEnvironment(DefaultOptions()), NewEnvironment(DefaultOptions())
+
+	r.register(e1, &signalHandler{interrupted: make(chan struct{})})
+	installed := r.signals
+	r.register(e2, &signalHandler{interrupted: make(chan struct{})})
+	if installed == nil || r.signals != installed {
+		t.Fatal("expected a single handler for all the environments")
+	}
+
+	r.unregister(e1)
+	if r.signals == nil {
+		t.Fatal("expected the handler to be kept while an environment is active")
+	}
+
+	r.unregister(e2)
+	if r.signals != nil {
+		t.Error("expected the handler to be uninstalled after the last environment")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package goit
+
+import (
+	"os"
+	"syscall"
+)
+
+// raise sends the signal again to the process, now that the handler is
+// uninstalled it terminates the process as it would without goit
+func raise(sig os.Signal) {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		os.Exit(1)
+	}
+	_ = syscall.Kill(os.Getpid(), s)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package goit
+
+import (
+	"os"
+)
+
+// raise terminates the process, windows doesn't support sending signals
+// to itself, so it exits as the process would do without goit
+func raise(sig os.Signal) {
+	os.Exit(1)
+}
@@ -0,0 +1,157 @@
+package goit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit/log"
+)
+
+const (
+	// labelSnapshot identifies the snapshot images by their key
+	labelSnapshot = "goit.snapshot"
+
+	// snapshotRepository prefixes the repository of the snapshot images
+	snapshotRepository = "goit-snapshot/"
+)
+
+var invalidRepositoryChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// containerWithSnapshotKey represents a docker container that can be
+// committed to a snapshot image and restored from it
+type containerWithSnapshotKey interface {
+	Container
+
+	// SnapshotKey identifies the content seeded into the container, e.g.
+	// the migrations version, changing it invalidates the snapshots
+	SnapshotKey() string
+}
+
+// snapshotKeyOf returns the snapshot key of the container, empty when
+// the container can't be snapshotted
+func snapshotKeyOf(c Container) string {
+	if cs, ok := c.(containerWithSnapshotKey); ok {
+		return cs.SnapshotKey()
+	}
+	return ""
+}
+
+// Snapshot commits a container started by goit to a local image, e.g.
+// after migrations and seed, environments with RestoreSnapshots start
+// the container from this image in the next runs and Restored reports it.
+//
+// The image is tagged with a hash of the container options and its
+// snapshot key, volumes and tmpfs mounts are not part of the snapshot.
+func Snapshot(ctx context.Context, c Container) (string, error) {
+	h := HandleOf(c)
+	if h == nil {
+		return "", errors.Errorf("container is not started: %s", describe(c))
+	}
+	return h.Snapshot(ctx)
+}
+
+// Restored reports if the container was started from a snapshot, so
+// seeding it again can be skipped
+func Restored(c Container) bool {
+	h := HandleOf(c)
+	return h != nil && h.Restored()
+}
+
+// Snapshot commits the container to a local image, see Snapshot
+func (h *Handle) Snapshot(ctx context.Context) (string, error) {
+	h.mu.RLock()
+	p, r, repo, tag := h.pool, h.resource, h.snapshotRepo, h.snapshotTag
+	h.mu.RUnlock()
+
+	if p == nil || r == nil {
+		return "", errors.New("container is not started")
+	}
+	if repo == "" {
+		return "", errors.Errorf("container has no snapshot key: %s", r.Container.Name)
+	}
+
+	// the session labels are cleared, otherwise the reaper would remove
+	// the image when the session ends
+	_, err := p.Client.CommitContainer(docker.CommitContainerOptions{
+		Context:    ctx,
+		Container:  r.Container.ID,
+		Repository: repo,
+		Tag:        tag,
+		Run: &docker.Config{
+			Labels: map[string]string{
+				labelSession:   "",
+				labelReuseHash: "",
+				labelSnapshot:  tag,
+			},
+		},
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to commit container: %s", r.Container.Name)
+	}
+
+	img := repo + ":" + tag
+	log.FromContext(ctx).Info("snapshot created", "container", r.Container.Name, "image", img)
+	return img, nil
+}
+
+// Restored reports if the container was started from a snapshot
+func (h *Handle) Restored() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.restored
+}
+
+// setSnapshot sets the snapshot image of the container
+func (h *Handle) setSnapshot(repo, tag string, restored bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.snapshotRepo = repo
+	h.snapshotTag = tag
+	h.restored = restored
+}
+
+// snapshotImage returns the snapshot image of a container created with
+// the options, named after the container or its repository, or empty
+// strings when the container has no snapshot key
+func snapshotImage(name string, b *dockertest.BuildOptions, o *dockertest.RunOptions, co createOptions) (repo, tag string, err error) {
+	if co.snapshotKey == "" {
+		return "", "", nil
+	}
+
+	h, err := reuseHash(b, o, co)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(h + "\n" + co.snapshotKey))
+
+	if name == "" {
+		name = o.Repository
+	}
+	name = invalidRepositoryChars.ReplaceAllString(strings.ToLower(name), "-")
+	return snapshotRepository + strings.Trim(name, "-._"), hex.EncodeToString(sum[:])[:20], nil
+}
+
+// restoreSnapshot points the options to the snapshot image when the
+// environment restores snapshots and the image exists
+func restoreSnapshot(ctx context.Context, p *dockertest.Pool, o *dockertest.RunOptions, repo, tag string, cfg startConfig) bool {
+	if !cfg.opt.RestoreSnapshots || repo == "" {
+		return false
+	}
+
+	img := repo + ":" + tag
+	if _, err := p.Client.InspectImage(img); err != nil {
+		return false
+	}
+
+	log.FromContext(ctx).Info("restoring container from snapshot", "image", img)
+	o.Repository, o.Tag = repo, tag
+	return true
+}
@@ -0,0 +1,43 @@
+package goit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ory/dockertest/v3"
+)
+
+func TestSnapshotImage(t *testing.T) {
+	o := &dockertest.RunOptions{Repository: "localstack/localstack", Tag: "latest", Env: []string{"B=2", "A=1"}}
+
+	repo, tag, err := snapshotImage("", nil, o, createOptions{})
+	if err != nil || repo != "" || tag != "" {
+		t.Errorf("expected no snapshot without key, found: %s:%s, err: %v", repo, tag, err)
+	}
+
+	repo, tag, err = snapshotImage("", nil, o, createOptions{snapshotKey: "v1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo != "goit-snapshot/localstack-localstack" {
+		t.Errorf("invalid repository, found: %s", repo)
+	}
+
+	// the env order doesn't change the options
+	o2 := *o
+	o2.Env = []string{"A=1", "B=2"}
+	_, tag2, _ := snapshotImage("", nil, &o2, createOptions{snapshotKey: "v1"})
+	if tag2 != tag {
+		t.Errorf("expected the same tag for the same options, found: %s and %s", tag, tag2)
+	}
+
+	_, tag3, _ := snapshotImage("", nil, o, createOptions{snapshotKey: "v2"})
+	if tag3 == tag {
+		t.Errorf("expected another tag for another key, found: %s", tag3)
+	}
+
+	repo, _, _ = snapshotImage("My App", nil, o, createOptions{snapshotKey: "v1"})
+	if !strings.HasSuffix(repo, "/my-app") {
+		t.Errorf("invalid repository, found: %s", repo)
+	}
+}
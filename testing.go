@@ -0,0 +1,42 @@
+package goit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tclemos/goit/log"
+)
+
+// StartT starts containers scoped to a single test or subtest with the
+// default options, see StartTWithOptions
+func StartT(t testing.TB, containers ...Container) *Environment {
+	t.Helper()
+	return StartTWithOptions(t, DefaultOptions(), containers...)
+}
+
+// StartTWithOptions starts containers scoped to a single test or subtest,
+// the goit messages are written with t.Logf and the containers are
+// stopped when the test finishes, the test fails if any container can't
+// be started. Each call creates its own Environment, so it can be used
+// from parallel tests as long as they don't share Container values.
+// When the test fails, the last lines of each container output are
+// written to the test output, the ones of a container that fails to
+// start are part of the start error.
+func StartTWithOptions(t testing.TB, opt Options, containers ...Container) *Environment {
+	t.Helper()
+
+	if opt.Logger == nil {
+		opt.Logger = log.NewTB(t)
+	}
+
+	env := NewEnvironment(opt)
+	t.Cleanup(env.Stop)
+
+	if err := env.StartE(context.Background(), containers...); err != nil {
+		t.Fatalf("failed to start containers: %v", err)
+	}
+
+	// registered after Stop, so it runs before the containers are purged
+	DumpLogsOnFailure(t, env.startedContainers()...)
+	return env
+}
@@ -0,0 +1,68 @@
+package goit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit/log"
+)
+
+// Main owns the whole TestMain lifecycle: it starts the containers, runs
+// the tests, stops the containers and exits with the tests result, e.g.
+//
+//	func TestMain(m *testing.M) {
+//		goit.Main(m, goit.DefaultOptions(), postgres.NewContainer(p))
+//	}
+//
+// Panics inside tests crash the test binary from another goroutine and
+// can't be recovered, the reaper removes the containers in that case.
+func Main(m *testing.M, opt Options, containers ...Container) {
+	os.Exit(runMain(m, opt, containers...))
+}
+
+func runMain(m *testing.M, opt Options, containers ...Container) int {
+	if Ctx == nil {
+		Ctx = context.Background()
+	}
+
+	if err := StartE(Ctx, opt, containers...); err != nil {
+		printStartError(getDefaultEnv(opt).log, err)
+		Stop()
+		return 1
+	}
+	defer Stop()
+
+	return Run(m)
+}
+
+// Run executes the tests, stopping the containers if it panics
+func Run(m *testing.M) int {
+	defer func() {
+		if err := recover(); err != nil {
+			Stop()
+			panic(fmt.Sprintf("rethrowing panic after stopping containers, err: %v", err))
+		}
+	}()
+	return m.Run()
+}
+
+// printStartError writes a summary of the startup failure to the logger
+// of the environment
+func printStartError(l log.Logger, err error) {
+	msg := "failed to start the integration test environment, tests were not executed"
+
+	var se *StartError
+	if errors.As(err, &se) {
+		kv := []interface{}{"container", se.Container, "phase", se.Phase, "err", se.Err}
+		if len(se.Logs) > 0 {
+			kv = append(kv, "logs", strings.Join(se.Logs, "\n"))
+		}
+		l.Error(msg, kv...)
+		return
+	}
+	l.Error(msg, "err", err)
+}
@@ -0,0 +1,171 @@
+package toxiproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Downstream toxics affect the data sent from the target to the client
+	Downstream = "downstream"
+
+	// Upstream toxics affect the data sent from the client to the target
+	Upstream = "upstream"
+)
+
+// Proxy routes the connections of a target port through toxiproxy
+type Proxy struct {
+	c *Container
+
+	Name     string `json:"name"`
+	Listen   string `json:"listen"`
+	Upstream string `json:"upstream"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// Toxic degrades the connections of a proxy, see the toxiproxy docs for
+// the types and their attributes
+type Toxic struct {
+	// Name identifies the toxic to remove it, defaults to <type>_<stream>
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+
+	// Stream is Downstream or Upstream, defaults to Downstream
+	Stream string `json:"stream,omitempty"`
+
+	// Toxicity is the probability of the toxic being applied to a
+	// connection, defaults to 1
+	Toxicity   float32                `json:"toxicity"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// Latency delays the data by latency, plus or minus jitter
+func Latency(latency, jitter time.Duration) Toxic {
+	return newToxic("latency", map[string]interface{}{
+		"latency": latency.Milliseconds(),
+		"jitter":  jitter.Milliseconds(),
+	})
+}
+
+// Bandwidth limits the data rate to kbps kilobytes per second
+func Bandwidth(kbps int64) Toxic {
+	return newToxic("bandwidth", map[string]interface{}{
+		"rate": kbps,
+	})
+}
+
+// Timeout stops the data and closes the connection after timeout, zero
+// keeps the connection open without data until the toxic is removed
+func Timeout(timeout time.Duration) Toxic {
+	return newToxic("timeout", map[string]interface{}{
+		"timeout": timeout.Milliseconds(),
+	})
+}
+
+// ResetPeer resets the connections with TCP RST after timeout
+func ResetPeer(timeout time.Duration) Toxic {
+	return newToxic("reset_peer", map[string]interface{}{
+		"timeout": timeout.Milliseconds(),
+	})
+}
+
+// SlowClose delays closing the connections by delay
+func SlowClose(delay time.Duration) Toxic {
+	return newToxic("slow_close", map[string]interface{}{
+		"delay": delay.Milliseconds(),
+	})
+}
+
+func newToxic(typ string, attrs map[string]interface{}) Toxic {
+	return Toxic{Type: typ, Stream: Downstream, Toxicity: 1, Attributes: attrs}
+}
+
+// AddToxic adds the toxic to the proxy, it returns the toxic name used
+// to remove it
+func (p *Proxy) AddToxic(ctx context.Context, t Toxic) (string, error) {
+	t = t.withDefaults()
+	err := p.c.do(ctx, http.MethodPost, "/proxies/"+p.Name+"/toxics", t, nil)
+	return t.Name, errors.Wrapf(err, "failed to add toxic %s to proxy %s", t.Name, p.Name)
+}
+
+// withDefaults returns the toxic with the defaults of its empty fields
+func (t Toxic) withDefaults() Toxic {
+	if t.Stream == "" {
+		t.Stream = Downstream
+	}
+	if t.Name == "" {
+		t.Name = fmt.Sprintf("%s_%s", t.Type, t.Stream)
+	}
+	if t.Toxicity == 0 {
+		t.Toxicity = 1
+	}
+	return t
+}
+
+// RemoveToxic removes the toxic from the proxy
+func (p *Proxy) RemoveToxic(ctx context.Context, name string) error {
+	err := p.c.do(ctx, http.MethodDelete, "/proxies/"+p.Name+"/toxics/"+name, nil, nil)
+	return errors.Wrapf(err, "failed to remove toxic %s from proxy %s", name, p.Name)
+}
+
+// Disable closes the connections of the proxy and refuses new ones,
+// simulating a network partition until Enable is called
+func (p *Proxy) Disable(ctx context.Context) error {
+	return p.setEnabled(ctx, false)
+}
+
+// Enable accepts connections again after Disable
+func (p *Proxy) Enable(ctx context.Context) error {
+	return p.setEnabled(ctx, true)
+}
+
+func (p *Proxy) setEnabled(ctx context.Context, enabled bool) error {
+	body := map[string]bool{"enabled": enabled}
+	if err := p.c.do(ctx, http.MethodPost, "/proxies/"+p.Name, body, nil); err != nil {
+		return errors.Wrapf(err, "failed to set proxy %s enabled: %v", p.Name, enabled)
+	}
+	p.Enabled = enabled
+	return nil
+}
+
+// do calls the toxiproxy api
+func (c *Container) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.URL("http", apiPort)+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 300 {
+		return errors.Errorf("toxiproxy answered %s %s with %d: %s", method, path, res.StatusCode, bytes.TrimSpace(b))
+	}
+
+	if out != nil {
+		return json.Unmarshal(b, out)
+	}
+	return nil
+}
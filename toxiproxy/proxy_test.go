@@ -0,0 +1,27 @@
+package toxiproxy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestToxicDefaults(t *testing.T) {
+	tx := Toxic{Type: "latency", Attributes: map[string]interface{}{"latency": 500}}.withDefaults()
+	if tx.Name != "latency_downstream" || tx.Stream != Downstream || tx.Toxicity != 1 {
+		t.Errorf("invalid defaults, found: %+v", tx)
+	}
+
+	b, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("Unable to encode toxic: %v", err)
+	}
+	if !strings.Contains(string(b), `"toxicity":1`) {
+		t.Errorf("expected toxicity 1 to be sent, found: %s", b)
+	}
+
+	tx = Toxic{Name: "slow", Type: "latency", Stream: Upstream, Toxicity: 0.5}.withDefaults()
+	if tx.Name != "slow" || tx.Stream != Upstream || tx.Toxicity != 0.5 {
+		t.Errorf("expected the fields to be kept, found: %+v", tx)
+	}
+}
@@ -0,0 +1,195 @@
+package toxiproxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit"
+	"github.com/tclemos/goit/log"
+	"github.com/tclemos/goit/wait"
+)
+
+const (
+	apiPort = "8474/tcp"
+
+	// firstListenPort is the toxiproxy port of the first proxy, the next
+	// proxies listen on the following ports
+	firstListenPort = 20000
+)
+
+// Target is a container port proxied by toxiproxy, containers whose
+// clients connect to addresses advertised by the container itself, e.g.
+// kafka, can't be targets, the clients would skip the proxy
+type Target struct {
+	Container goit.Container
+
+	// Port of the container, e.g. 5432/tcp
+	Port string
+
+	// Name of the proxy, defaults to the container alias and port, e.g. postgres_5432
+	Name string
+}
+
+// proxyRejecter is implemented by the containers that can't be targets
+type proxyRejecter interface {
+	// RejectProxy returns why the port can't be routed through a proxy
+	RejectProxy(port string) error
+}
+
+// Params needed to start a toxiproxy container
+type Params struct {
+	goit.ContainerParams
+
+	// Targets are the container ports routed through toxiproxy, their
+	// endpoints and urls point to toxiproxy once it is started
+	Targets []Target
+}
+
+// Container metadata to load a container for toxiproxy
+type Container struct {
+	goit.Handle
+	goit.Spec
+	params  Params
+	client  *http.Client
+	proxies []*Proxy
+}
+
+// NewContainer creates a new instance of Container
+func NewContainer(p Params) *Container {
+	return &Container{
+		Spec:   p.Spec(),
+		params: p,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Options to start a toxiproxy container accordingly to the params
+func (c *Container) Options() (*dockertest.RunOptions, error) {
+	ports := []string{apiPort}
+	for i, t := range c.params.Targets {
+		if r, ok := t.Container.(proxyRejecter); ok {
+			if err := r.RejectProxy(t.Port); err != nil {
+				return nil, errors.Wrapf(err, "invalid proxy target %T port %s", t.Container, t.Port)
+			}
+		}
+		ports = append(ports, listenPort(i))
+	}
+
+	repo, tag := c.params.GetRepoTag("ghcr.io/shopify/toxiproxy", "2.5.0")
+	return &dockertest.RunOptions{
+		Hostname:     c.params.GetAlias("toxiproxy"),
+		Repository:   repo,
+		Tag:          tag,
+		Auth:         c.params.Auth,
+		Env:          c.params.MergeEnv(nil),
+		ExposedPorts: ports,
+	}, nil
+}
+
+// AfterStart creates a proxy for each target and routes the target
+// endpoints through it
+func (c *Container) AfterStart(ctx context.Context, r *dockertest.Resource) error {
+	l := log.FromContext(ctx)
+
+	proxies := make([]*Proxy, len(c.params.Targets))
+	for i, t := range c.params.Targets {
+		h := goit.HandleOf(t.Container)
+		if h == nil {
+			return errors.Errorf("proxy target is not started: %T", t.Container)
+		}
+
+		name := t.Name
+		if name == "" {
+			name = fmt.Sprintf("%s_%s", h.Alias(), portNumber(t.Port))
+		}
+
+		proxies[i] = &Proxy{
+			c:        c,
+			Name:     name,
+			Listen:   net.JoinHostPort("0.0.0.0", portNumber(listenPort(i))),
+			Upstream: net.JoinHostPort(h.Alias(), portNumber(t.Port)),
+			Enabled:  true,
+		}
+	}
+
+	// populate creates the proxies or updates the existing ones, e.g. when
+	// the container is reused
+	if err := c.do(ctx, http.MethodPost, "/populate", proxies, nil); err != nil {
+		l.Error("failed to create proxies", "err", err)
+		return err
+	}
+
+	for i, t := range c.params.Targets {
+		goit.HandleOf(t.Container).SetProxy(t.Port, &c.Handle, listenPort(i))
+		l.Info("proxy created", "proxy", proxies[i].Name, "listen", c.HostAddress(listenPort(i)), "upstream", proxies[i].Upstream)
+	}
+	c.proxies = proxies
+	return nil
+}
+
+// WaitStrategy waits until the toxiproxy api answers, unless the params
+// provide another strategy
+func (c *Container) WaitStrategy() wait.Strategy {
+	if c.params.WaitFor != nil {
+		return c.params.WaitFor
+	}
+	return wait.ForHTTP(apiPort, "/version")
+}
+
+// DependsOn returns the targets and the containers that must be started
+// before this one
+func (c *Container) DependsOn() []goit.Container {
+	deps := append([]goit.Container{}, c.params.DependsOn...)
+	for _, t := range c.params.Targets {
+		deps = append(deps, t.Container)
+	}
+	return deps
+}
+
+// Proxy returns the proxy in front of the container port, or nil if the
+// port isn't proxied
+func (c *Container) Proxy(target goit.Container, port string) *Proxy {
+	for i, t := range c.params.Targets {
+		if t.Container == target && portNumber(t.Port) == portNumber(port) && i < len(c.proxies) {
+			return c.proxies[i]
+		}
+	}
+	return nil
+}
+
+// ProxyByName returns the proxy with the name, or nil if it doesn't exist
+func (c *Container) ProxyByName(name string) *Proxy {
+	for _, p := range c.proxies {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Reset removes the toxics of all proxies and enables them
+func (c *Container) Reset(ctx context.Context) error {
+	if err := c.do(ctx, http.MethodPost, "/reset", nil, nil); err != nil {
+		return err
+	}
+	for _, p := range c.proxies {
+		p.Enabled = true
+	}
+	return nil
+}
+
+// listenPort returns the toxiproxy port of the proxy at the index
+func listenPort(i int) string {
+	return fmt.Sprintf("%d/tcp", firstListenPort+i)
+}
+
+// portNumber returns the number of a container port, e.g. 5432 for 5432/tcp
+func portNumber(port string) string {
+	return strings.Split(port, "/")[0]
+}
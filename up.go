@@ -0,0 +1,175 @@
+package goit
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+	"github.com/tclemos/goit/log"
+)
+
+const (
+	// LabelUp identifies the containers, volumes and networks of the
+	// environments started by goit up, its value is the environment name
+	LabelUp = "goit.up"
+
+	// envAttach names the goit up environment to attach to, false disables
+	// attaching
+	envAttach = "GOIT_ATTACH"
+)
+
+// NewPool connects to the docker daemon goit uses, from DOCKER_HOST or,
+// when it is not set, from the current docker context
+func NewPool() (*dockertest.Pool, error) {
+	return newPool()
+}
+
+// upOptions returns the options of a goit up environment, its containers
+// are reused by the next runs and outlive the process, so the reaper is
+// disabled
+func upOptions(opt Options) Options {
+	if opt.Up == "" {
+		return opt
+	}
+	opt.Reuse = true
+	opt.DisableReaper = true
+	return opt
+}
+
+// upLabels returns the labels of the resources of a goit up environment
+func upLabels(opt Options) map[string]string {
+	if opt.Up == "" {
+		return nil
+	}
+	return map[string]string{LabelUp: opt.Up}
+}
+
+// attachName returns the goit up environment the environment attaches
+// to, GOIT_ATTACH replaces the options, empty when it doesn't attach
+func attachName(opt Options) string {
+	if opt.Up != "" || opt.Reuse {
+		// environments reusing containers find them by themselves
+		return ""
+	}
+
+	name := opt.Attach
+	if v, ok := os.LookupEnv(envAttach); ok {
+		name = v
+	}
+	if v, err := strconv.ParseBool(name); err == nil && !v {
+		return ""
+	}
+	return name
+}
+
+// attachTo returns the goit up environment whose containers replace the
+// ones of the environment, empty when it isn't running
+func attachTo(ctx context.Context, p *dockertest.Pool, opt Options) string {
+	name := attachName(opt)
+	if name == "" {
+		return ""
+	}
+
+	l := log.FromContext(ctx)
+	cs, err := p.Client.ListContainers(docker.ListContainersOptions{
+		Filters: map[string][]string{"label": {LabelUp + "=" + name}},
+	})
+	if err != nil {
+		l.Warn("failed to look for goit up environment", "environment", name, "err", err)
+		return ""
+	}
+	if len(cs) == 0 {
+		l.Debug("goit up environment is not running", "environment", name)
+		return ""
+	}
+
+	l.Info("attaching to goit up environment", "environment", name)
+	return name
+}
+
+// findAttachable returns a running and healthy container of the goit up
+// environment created with the same options, the containers that can't
+// be attached are left untouched, they belong to goit up. Starting a new
+// container with the name of one of them would collide, so it fails
+func findAttachable(p *dockertest.Pool, env, hash, name string) (*dockertest.Resource, error) {
+	cs, err := p.Client.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Filters: map[string][]string{"label": {LabelUp + "=" + env}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range cs {
+		if c.Labels[labelReuseHash] != hash {
+			continue
+		}
+		r, err := getResource(p, c.ID)
+		if err == nil && isHealthy(r.Container) {
+			return r, nil
+		}
+	}
+
+	for _, c := range cs {
+		for _, n := range c.Names {
+			if name != "" && strings.TrimPrefix(n, "/") == name {
+				return nil, errors.Errorf("container %s of goit up environment %s can't be attached, it was created with other options or isn't running, run goit up again or set %s=false", name, env, envAttach)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Down removes the containers, volumes and networks of the goit up
+// environment with the name, or of all of them when the name is empty
+func Down(ctx context.Context, name string) error {
+	l := log.FromContext(ctx)
+	p, err := newPool()
+	if err != nil {
+		return err
+	}
+
+	filter := LabelUp
+	if name != "" {
+		filter += "=" + name
+	}
+	filters := map[string][]string{"label": {filter}}
+
+	cs, err := p.Client.ListContainers(docker.ListContainersOptions{All: true, Filters: filters})
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		l.Info("removing container", "container", c.Names[0], "environment", c.Labels[LabelUp])
+		err := p.Client.RemoveContainer(docker.RemoveContainerOptions{ID: c.ID, Force: true, RemoveVolumes: true, Context: ctx})
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove container: %s", c.Names[0])
+		}
+	}
+
+	vs, err := p.Client.ListVolumes(docker.ListVolumesOptions{Filters: filters, Context: ctx})
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, v := range vs {
+		names = append(names, v.Name)
+	}
+	removeVolumes(l, p, names)
+
+	ns, err := p.Client.FilteredListNetworks(docker.NetworkFilterOpts{"label": {filter: true}})
+	if err != nil {
+		return err
+	}
+	for _, n := range ns {
+		l.Info("removing network", "network", n.Name)
+		if err := p.Client.RemoveNetwork(n.ID); err != nil {
+			return errors.Wrapf(err, "failed to remove network: %s", n.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package goit
+
+import "testing"
+
+func TestUpOptions(t *testing.T) {
+	opt := upOptions(Options{Up: "dev"})
+	if !opt.Reuse || !opt.DisableReaper {
+		t.Errorf("expected goit up to reuse containers without reaper, found: %+v", opt)
+	}
+	if l := upLabels(opt); l[LabelUp] != "dev" {
+		t.Errorf("expected the environment name as label, found: %v", l)
+	}
+
+	opt = upOptions(DefaultOptions())
+	if opt.Reuse || opt.DisableReaper || upLabels(opt) != nil {
+		t.Errorf("expected the options to be kept, found: %+v", opt)
+	}
+}
+
+func TestAttachName(t *testing.T) {
+	cases := []struct {
+		opt  Options
+		env  string
+		want string
+	}{
+		{opt: Options{}, want: ""},
+		{opt: Options{Attach: "dev"}, want: "dev"},
+		{opt: Options{Attach: "dev"}, env: "false", want: ""},
+		{opt: Options{}, env: "other", want: "other"},
+		{opt: Options{Attach: "dev", Reuse: true}, want: ""},
+		{opt: Options{Attach: "dev", Up: "dev"}, want: ""},
+	}
+	for _, c := range cases {
+		t.Run(c.want, func(t *testing.T) {
+			if c.env != "" {
+				setenv(t, envAttach, c.env)
+			}
+			if got := attachName(c.opt); got != c.want {
+				t.Errorf("expected %q for %+v with %s=%q, found: %q", c.want, c.opt, envAttach, c.env, got)
+			}
+		})
+	}
+}
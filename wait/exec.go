@@ -0,0 +1,50 @@
+package wait
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/pkg/errors"
+)
+
+// ExecStrategy waits until a command executed inside the container
+// exits with the expected code
+type ExecStrategy struct {
+	settings
+	cmd      []string
+	exitCode int
+}
+
+// ForExec waits until the command, e.g. pg_isready, exits with code zero
+func ForExec(cmd []string, opts ...Option) *ExecStrategy {
+	return &ExecStrategy{
+		settings: newSettings(opts),
+		cmd:      cmd,
+	}
+}
+
+// WithExitCode sets the expected exit code
+func (s *ExecStrategy) WithExitCode(code int) *ExecStrategy {
+	s.exitCode = code
+	return s
+}
+
+// WaitUntilReady executes the command until it exits with the expected code
+func (s *ExecStrategy) WaitUntilReady(ctx context.Context, t Target) error {
+	return s.poll(ctx, func(ctx context.Context) error {
+		var out bytes.Buffer
+		code, err := t.Resource().Exec(s.cmd, dockertest.ExecOptions{
+			StdOut: &out,
+			StdErr: &out,
+		})
+		if err != nil {
+			return err
+		}
+
+		if code != s.exitCode {
+			return errors.Errorf("%v exited with %d, expected %d, output: %s", s.cmd, code, s.exitCode, out.String())
+		}
+		return nil
+	})
+}
@@ -0,0 +1,46 @@
+package wait
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+const healthy = "healthy"
+
+// HealthStrategy waits until docker reports the container HEALTHCHECK as healthy
+type HealthStrategy struct {
+	settings
+}
+
+// ForHealthCheck waits until the container HEALTHCHECK status is healthy,
+// the image must declare a HEALTHCHECK
+func ForHealthCheck(opts ...Option) *HealthStrategy {
+	return &HealthStrategy{
+		settings: newSettings(opts),
+	}
+}
+
+// WaitUntilReady inspects the container until its health status is healthy
+func (s *HealthStrategy) WaitUntilReady(ctx context.Context, t Target) error {
+	id := t.Resource().Container.ID
+	c, err := t.Pool().Client.InspectContainerWithContext(id, ctx)
+	if err != nil {
+		return err
+	}
+	if c.Config == nil || c.Config.Healthcheck == nil {
+		return errors.Errorf("container %s has no HEALTHCHECK", c.Name)
+	}
+
+	return s.poll(ctx, func(ctx context.Context) error {
+		c, err := t.Pool().Client.InspectContainerWithContext(id, ctx)
+		if err != nil {
+			return err
+		}
+
+		if c.State.Health.Status != healthy {
+			return errors.Errorf("container %s is %s", c.Name, c.State.Health.Status)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,92 @@
+package wait
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPStrategy waits until a container endpoint answers with the expected
+// status code and body
+type HTTPStrategy struct {
+	settings
+	port       string
+	path       string
+	scheme     string
+	statusCode int
+	body       *regexp.Regexp
+	client     *http.Client
+}
+
+// ForHTTP waits until a GET to the path on the container port, e.g.
+// 8080/tcp, answers 200 OK
+func ForHTTP(port, path string, opts ...Option) *HTTPStrategy {
+	return &HTTPStrategy{
+		settings:   newSettings(opts),
+		port:       port,
+		path:       path,
+		scheme:     "http",
+		statusCode: http.StatusOK,
+		client:     http.DefaultClient,
+	}
+}
+
+// WithStatusCode sets the expected status code
+func (s *HTTPStrategy) WithStatusCode(code int) *HTTPStrategy {
+	s.statusCode = code
+	return s
+}
+
+// WithBody sets a regular expression the response body must match
+func (s *HTTPStrategy) WithBody(pattern string) *HTTPStrategy {
+	s.body = regexp.MustCompile(pattern)
+	return s
+}
+
+// WithTLS uses https and the provided client to make the requests
+func (s *HTTPStrategy) WithTLS(client *http.Client) *HTTPStrategy {
+	s.scheme = "https"
+	s.client = client
+	return s
+}
+
+// WaitUntilReady requests the endpoint until it answers as expected
+func (s *HTTPStrategy) WaitUntilReady(ctx context.Context, t Target) error {
+	return s.poll(ctx, func(ctx context.Context) error {
+		addr := t.HostAddress(s.port)
+		if addr == "" {
+			return errors.Errorf("port %s is not mapped to the host", s.port)
+		}
+
+		url := fmt.Sprintf("%s://%s%s", s.scheme, addr, s.path)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		res, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != s.statusCode {
+			return errors.Errorf("%s answered %d, expected %d", url, res.StatusCode, s.statusCode)
+		}
+
+		if s.body != nil {
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				return err
+			}
+			if !s.body.Match(b) {
+				return errors.Errorf("%s body doesn't match %q", url, s.body)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,88 @@
+package wait
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pkg/errors"
+)
+
+// LogStrategy waits until the container writes a line matching a pattern
+type LogStrategy struct {
+	settings
+	pattern     *regexp.Regexp
+	occurrences int
+}
+
+// ForLog waits until the container output matches the regular expression
+func ForLog(pattern string, opts ...Option) *LogStrategy {
+	return &LogStrategy{
+		settings:    newSettings(opts),
+		pattern:     regexp.MustCompile(pattern),
+		occurrences: 1,
+	}
+}
+
+// WithOccurrences sets how many times the pattern must be found, useful
+// for images that restart their process during initialization
+func (s *LogStrategy) WithOccurrences(n int) *LogStrategy {
+	s.occurrences = n
+	return s
+}
+
+// WaitUntilReady reads the container logs until the pattern is found,
+// only the output since the container was last started is read, so a
+// restarted container isn't ready because of its previous run
+func (s *LogStrategy) WaitUntilReady(ctx context.Context, t Target) error {
+	return s.poll(ctx, func(ctx context.Context) error {
+		p, id := t.Pool(), t.Resource().Container.ID
+		c, err := p.Client.InspectContainerWithContext(id, ctx)
+		if err != nil {
+			return err
+		}
+		started := c.State.StartedAt
+
+		var b bytes.Buffer
+		err = p.Client.Logs(docker.LogsOptions{
+			Context:      ctx,
+			Container:    id,
+			Stdout:       true,
+			Stderr:       true,
+			Since:        started.Unix(),
+			Timestamps:   true,
+			OutputStream: &b,
+			ErrorStream:  &b,
+		})
+		if err != nil {
+			return err
+		}
+
+		found := len(s.pattern.FindAllIndex(linesSince(b.Bytes(), started), -1))
+		if found < s.occurrences {
+			return errors.Errorf("log %q found %d of %d times", s.pattern, found, s.occurrences)
+		}
+		return nil
+	})
+}
+
+// linesSince returns the lines of timestamped logs written from the time
+// on, without their timestamps, docker filters the logs by seconds only
+func linesSince(b []byte, t time.Time) []byte {
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		i := bytes.IndexByte(line, ' ')
+		if i < 0 {
+			continue
+		}
+
+		ts, err := time.Parse(time.RFC3339Nano, string(line[:i]))
+		if err != nil || ts.Before(t) {
+			continue
+		}
+		out.Write(line[i+1:])
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,40 @@
+package wait
+
+import (
+	"context"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// PortStrategy waits until a container port accepts connections from the host
+type PortStrategy struct {
+	settings
+	port string
+}
+
+// ForListeningPort waits until the container port, e.g. 5432/tcp, accepts
+// tcp connections through its host mapped address
+func ForListeningPort(port string, opts ...Option) *PortStrategy {
+	return &PortStrategy{
+		settings: newSettings(opts),
+		port:     port,
+	}
+}
+
+// WaitUntilReady dials the host mapped port until it succeeds
+func (s *PortStrategy) WaitUntilReady(ctx context.Context, t Target) error {
+	return s.poll(ctx, func(ctx context.Context) error {
+		addr := t.HostAddress(s.port)
+		if addr == "" {
+			return errors.Errorf("port %s is not mapped to the host", s.port)
+		}
+
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
@@ -0,0 +1,58 @@
+package wait
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// SQLStrategy waits until a database accepts connections and queries
+type SQLStrategy struct {
+	settings
+	driver string
+	port   string
+	dsn    func(addr string) string
+	query  string
+}
+
+// ForSQL waits until the database on the container port answers a query,
+// dsn builds the data source name from the host mapped address and the
+// driver must be registered by the caller, e.g. importing pgx/v4/stdlib
+func ForSQL(driver, port string, dsn func(addr string) string, opts ...Option) *SQLStrategy {
+	return &SQLStrategy{
+		settings: newSettings(opts),
+		driver:   driver,
+		port:     port,
+		dsn:      dsn,
+		query:    "SELECT 1",
+	}
+}
+
+// WithQuery sets the query used to check the database, defaults to SELECT 1
+func (s *SQLStrategy) WithQuery(q string) *SQLStrategy {
+	s.query = q
+	return s
+}
+
+// WaitUntilReady connects to the database and runs the query until it succeeds
+func (s *SQLStrategy) WaitUntilReady(ctx context.Context, t Target) error {
+	return s.poll(ctx, func(ctx context.Context) error {
+		addr := t.HostAddress(s.port)
+		if addr == "" {
+			return errors.Errorf("port %s is not mapped to the host", s.port)
+		}
+
+		db, err := sql.Open(s.driver, s.dsn(addr))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		rows, err := db.QueryContext(ctx, s.query)
+		if err != nil {
+			return err
+		}
+		return rows.Close()
+	})
+}
@@ -0,0 +1,127 @@
+// Package wait provides strategies to wait until a container is ready to
+// be used, they can be set to any goit container, e.g.
+//
+//	postgres.NewContainer(postgres.Params{
+//		ContainerParams: goit.ContainerParams{
+//			WaitFor: wait.ForLog("database system is ready to accept connections"),
+//		},
+//	})
+package wait
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/pkg/errors"
+	"github.com/sethvargo/go-retry"
+)
+
+const (
+	defaultTimeout     = 60 * time.Second
+	defaultInterval    = 500 * time.Millisecond
+	defaultMaxInterval = 10 * time.Second
+)
+
+// Target is the container a Strategy waits for
+type Target interface {
+	// Pool connected to the docker daemon running the container
+	Pool() *dockertest.Pool
+
+	// Resource of the started container
+	Resource() *dockertest.Resource
+
+	// HostAddress returns the address the host uses to reach the container port
+	HostAddress(port string) string
+}
+
+// Strategy waits until a container is ready to be used
+type Strategy interface {
+	WaitUntilReady(ctx context.Context, t Target) error
+}
+
+// Option changes the timeout and backoff used by a strategy
+type Option func(*settings)
+
+// WithTimeout sets how long the strategy waits before giving up, defaults to 60 seconds
+func WithTimeout(d time.Duration) Option {
+	return func(s *settings) {
+		s.timeout = d
+	}
+}
+
+// WithInterval sets the first interval between checks, the next ones grow
+// following a Fibonacci backoff, defaults to 500 milliseconds
+func WithInterval(d time.Duration) Option {
+	return func(s *settings) {
+		s.interval = d
+	}
+}
+
+// WithMaxInterval caps the interval between checks, defaults to 10 seconds
+func WithMaxInterval(d time.Duration) Option {
+	return func(s *settings) {
+		s.maxInterval = d
+	}
+}
+
+// settings shared by all the strategies
+type settings struct {
+	timeout     time.Duration
+	interval    time.Duration
+	maxInterval time.Duration
+}
+
+func newSettings(opts []Option) settings {
+	s := settings{
+		timeout:     defaultTimeout,
+		interval:    defaultInterval,
+		maxInterval: defaultMaxInterval,
+	}
+	for _, o := range opts {
+		o(&s)
+	}
+	return s
+}
+
+// poll executes check until it succeeds or the timeout is reached, the
+// last check error is returned on timeouts
+func (s settings) poll(ctx context.Context, check func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	b, err := retry.NewFibonacci(s.interval)
+	if err != nil {
+		return errors.Wrap(err, "failed to configure wait backoff")
+	}
+	b = retry.WithCappedDuration(s.maxInterval, b)
+
+	var last error
+	err = retry.Do(ctx, b, func(ctx context.Context) error {
+		last = check(ctx)
+		if last != nil {
+			return retry.RetryableError(last)
+		}
+		return nil
+	})
+	if err != nil && last != nil {
+		return errors.Wrapf(last, "container not ready after %s", s.timeout)
+	}
+	return err
+}
+
+// ForAll waits for all the strategies, one after another
+func ForAll(strategies ...Strategy) Strategy {
+	return allStrategy(strategies)
+}
+
+type allStrategy []Strategy
+
+func (a allStrategy) WaitUntilReady(ctx context.Context, t Target) error {
+	for _, s := range a {
+		if err := s.WaitUntilReady(ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
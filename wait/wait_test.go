@@ -0,0 +1,188 @@
+package wait
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// fakeTarget maps every container port to the same host address
+type fakeTarget struct {
+	addr string
+}
+
+func (t fakeTarget) Pool() *dockertest.Pool {
+	return nil
+}
+
+func (t fakeTarget) Resource() *dockertest.Resource {
+	return nil
+}
+
+func (t fakeTarget) HostAddress(port string) string {
+	return t.addr
+}
+
+func TestForListeningPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Unable to listen: %v", err)
+		return
+	}
+	defer l.Close()
+
+	s := ForListeningPort("5432/tcp", WithTimeout(time.Second))
+	if err := s.WaitUntilReady(context.Background(), fakeTarget{addr: l.Addr().String()}); err != nil {
+		t.Errorf("Invalid result, expected port to be ready, found: %v", err)
+	}
+}
+
+func TestForListeningPortTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Unable to listen: %v", err)
+		return
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	s := ForListeningPort("5432/tcp", WithTimeout(100*time.Millisecond), WithInterval(10*time.Millisecond))
+	err = s.WaitUntilReady(context.Background(), fakeTarget{addr: addr})
+	if err == nil || !strings.Contains(err.Error(), "not ready after") {
+		t.Errorf("Invalid result, expected timeout error, found: %v", err)
+	}
+}
+
+func TestForHTTP(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("pong"))
+	}))
+	defer srv.Close()
+
+	target := fakeTarget{addr: strings.TrimPrefix(srv.URL, "http://")}
+	s := ForHTTP("8080/tcp", "/ping", WithTimeout(time.Second), WithInterval(10*time.Millisecond)).WithBody("^pong$")
+	if err := s.WaitUntilReady(context.Background(), target); err != nil {
+		t.Errorf("Invalid result, expected endpoint to be ready, found: %v", err)
+		return
+	}
+
+	if calls != 3 {
+		t.Errorf("Invalid calls, expected 3, found: %d", calls)
+	}
+}
+
+// fakeDaemon serves the inspect and logs of a single container as the
+// docker daemon does
+type fakeDaemon struct {
+	mu      sync.Mutex
+	started time.Time
+	lines   []logLine
+}
+
+type logLine struct {
+	at   time.Time
+	text string
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/json"):
+		json.NewEncoder(w).Encode(docker.Container{ID: "abc", State: docker.State{Running: true, StartedAt: d.started}})
+	case strings.HasSuffix(r.URL.Path, "/logs"):
+		since, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+		for _, l := range d.lines {
+			if l.at.Unix() < since {
+				continue
+			}
+
+			// stdout frames, see stdcopy
+			frame := []byte(l.at.Format(time.RFC3339Nano) + " " + l.text + "\n")
+			header := make([]byte, 8)
+			header[0] = 1
+			binary.BigEndian.PutUint32(header[4:], uint32(len(frame)))
+			w.Write(append(header, frame...))
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (d *fakeDaemon) restart(at time.Time, lines ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.started = at
+	for i, l := range lines {
+		d.lines = append(d.lines, logLine{at: at.Add(time.Duration(i+1) * time.Millisecond), text: l})
+	}
+}
+
+// daemonTarget is a container served by a fakeDaemon
+type daemonTarget struct {
+	pool *dockertest.Pool
+}
+
+func (t daemonTarget) Pool() *dockertest.Pool {
+	return t.pool
+}
+
+func (t daemonTarget) Resource() *dockertest.Resource {
+	return &dockertest.Resource{Container: &docker.Container{ID: "abc"}}
+}
+
+func (t daemonTarget) HostAddress(port string) string {
+	return ""
+}
+
+func TestForLogAfterRestart(t *testing.T) {
+	d := &fakeDaemon{}
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+
+	client, err := docker.NewClient(srv.URL)
+	if err != nil {
+		t.Errorf("Unable to create client: %v", err)
+		return
+	}
+	target := daemonTarget{pool: &dockertest.Pool{Client: client}}
+	s := ForLog("(?m)^Ready\\.", WithTimeout(200*time.Millisecond), WithInterval(10*time.Millisecond))
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.restart(start, "Starting", "Ready.")
+	if err := s.WaitUntilReady(context.Background(), target); err != nil {
+		t.Errorf("Invalid result, expected container to be ready, found: %v", err)
+		return
+	}
+
+	// restarted in the same second, the previous Ready. must not count
+	d.restart(start.Add(500*time.Millisecond), "Starting")
+	if err := s.WaitUntilReady(context.Background(), target); err == nil {
+		t.Errorf("Invalid result, expected restarted container not to be ready")
+		return
+	}
+
+	d.restart(start.Add(500*time.Millisecond), "Ready.")
+	if err := s.WaitUntilReady(context.Background(), target); err != nil {
+		t.Errorf("Invalid result, expected restarted container to be ready, found: %v", err)
+	}
+}